@@ -1,26 +1,156 @@
 package configs
 
 import (
-	"log"
+	"fmt"
+	"os"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+type Format string
+
+const (
+	FormatSprint     Format = "sprint"
+	FormatIndividual Format = "individual"
+	FormatPursuit    Format = "pursuit"
+	FormatMassStart  Format = "mass-start"
+
+	FormatRelay            Format = "relay"
+	FormatMixedRelay       Format = "mixed-relay"
+	FormatSingleMixedRelay Format = "single-mixed-relay"
+)
+
+func (f Format) IsRelay() bool {
+	return f == FormatRelay || f == FormatMixedRelay || f == FormatSingleMixedRelay
+}
+
+// Team is a relay team with the athlete IDs of its legs in running order. In a
+// single mixed relay the two athletes alternate, so IDs repeat.
+type Team struct {
+	ID   int   `json:"id"   yaml:"id"`
+	Legs []int `json:"legs" yaml:"legs"`
+}
+
+// FiringLine describes a firing line of the course: the shooting position,
+// the number of targets and the number of shots allowed. Shots above the
+// number of targets are spare rounds.
+type FiringLine struct {
+	Position string `json:"position" yaml:"position"`
+	Targets  int    `json:"targets"  yaml:"targets"`
+	Shots    int    `json:"shots"    yaml:"shots"`
+}
+
+// DefaultSpareRounds is the number of spare rounds per relay firing line when
+// the config does not set relay.spareRounds.
+const DefaultSpareRounds = 3
+
+type Relay struct {
+	SpareRounds int    `json:"spareRounds" yaml:"spareRounds"`
+	Teams       []Team `json:"teams"       yaml:"teams"`
+}
+
+// Role is what an API token may do. Each role includes the ones before it.
+type Role string
+
+const (
+	// RoleViewer reads the standings, the competitors and the output log.
+	RoleViewer Role = "viewer"
+	// RoleTiming also submits the incoming events of the timing devices: 1-11,
+	// and in relays the hand-overs and spare rounds.
+	RoleTiming Role = "timing"
+	// RoleJury also disqualifies competitors with event 14. Any other event id
+	// is passed on to the race, which rejects the ones its format does not know.
+	RoleJury Role = "jury"
+)
+
+// Includes reports whether the role has every permission of other.
+func (r Role) Includes(other Role) bool {
+	return r.rank() >= other.rank() && other.rank() > 0
+}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleTiming:
+		return 2
+	case RoleJury:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Token is an API access token. Name identifies the holder in the audit log.
+type Token struct {
+	Name  string `json:"name"  yaml:"name"`
+	Token string `json:"token" yaml:"token"`
+	Role  Role   `json:"role"  yaml:"role"`
+}
+
 type Config struct {
-	Laps          int    `json:"laps"`
-	LapLength     int    `json:"lapLen"`
-	PenaltyLength int    `json:"penaltyLen"`
-	FiringLines   int    `json:"firingLines"`
-	Start         string `json:"start"`
-	StartDelta    string `json:"startDelta"`
+	Format             Format       `json:"format"             yaml:"format"             env-default:"sprint"`
+	Laps               int          `json:"laps"               yaml:"laps"`
+	LapLength          int          `json:"lapLen"             yaml:"lapLen"`
+	LapLengths         []int        `json:"lapLengths"         yaml:"lapLengths"`
+	PenaltyLength      int          `json:"penaltyLen"         yaml:"penaltyLen"`
+	FiringLines        int          `json:"firingLines"        yaml:"firingLines"`
+	ShotsPerLine       int          `json:"shotsPerLine"       yaml:"shotsPerLine"       env-default:"5"`
+	PenaltySpeed       float64      `json:"penaltySpeed"       yaml:"penaltySpeed"`
+	SkippedLoopPenalty Duration     `json:"skippedLoopPenalty" yaml:"skippedLoopPenalty" env:"BIATHLON_SKIPPED_LOOP_PENALTY"`
+	Start              Clock        `json:"start"              yaml:"start"              env:"BIATHLON_START"`
+	StartDelta         Duration     `json:"startDelta"         yaml:"startDelta"         env:"BIATHLON_START_DELTA"`
+	Shooting           []FiringLine `json:"shooting"           yaml:"shooting"`
+	Relay              Relay        `json:"relay"              yaml:"relay"`
+	Tokens             []Token      `json:"tokens"             yaml:"tokens"`
+
+	// startMissing is set by LoadConfig when neither the file nor the
+	// environment gives the start, which would otherwise read as midnight.
+	startMissing bool
+}
+
+// startEnv is the environment variable in the env tag of Config.Start.
+const startEnv = "BIATHLON_START"
+
+// given records which of the settings whose zero value is valid the config
+// file sets, so a missing one can be told from a zero.
+type given struct {
+	Start *Clock `json:"start" yaml:"start"`
+	Relay struct {
+		SpareRounds *int `json:"spareRounds" yaml:"spareRounds"`
+	} `json:"relay" yaml:"relay"`
 }
 
-func LoadConfig(configPath string) *Config {
+func LoadConfig(configPath string) (*Config, error) {
 	var cfg Config
+	var set given
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
+	if err := cleanenv.ReadConfig(configPath, &set); err != nil {
+		return nil, fmt.Errorf("cannot read config: %w", err)
+	}
+
+	_, startInEnv := os.LookupEnv(startEnv)
+	cfg.startMissing = set.Start == nil && !startInEnv
 
-	return &cfg
+	if set.Relay.SpareRounds == nil {
+		cfg.Relay.SpareRounds = DefaultSpareRounds
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// LapLengthOf returns the length of the lap with the given zero-based index.
+// Without a lapLengths list every lap is lapLen long.
+func (c *Config) LapLengthOf(lap int) int {
+	if lap >= 0 && lap < len(c.LapLengths) {
+		return c.LapLengths[lap]
+	}
+	return c.LapLength
 }
@@ -0,0 +1,319 @@
+package configs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `{
+		"laps": 2,
+		"lapLen": 3500,
+		"penaltyLen": 150,
+		"firingLines": 2,
+		"start": "10:00:00.000",
+		"startDelta": "00:01:30"
+	}`)
+
+	cfg, err := configs.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, configs.FormatSprint, cfg.Format)
+	assert.Equal(t, 5, cfg.ShotsPerLine)
+	assert.Equal(t, 3500, cfg.LapLengthOf(1))
+	assert.Equal(t, configs.Clock(10*time.Hour), cfg.Start)
+	assert.Equal(t, 90*time.Second, cfg.StartDelta.Duration())
+}
+
+func TestLoadConfigMidnightStart(t *testing.T) {
+	path := writeConfig(t, `{
+		"laps": 2,
+		"lapLen": 3500,
+		"penaltyLen": 150,
+		"firingLines": 2,
+		"start": "00:00:00",
+		"startDelta": "00:01:30"
+	}`)
+
+	cfg, err := configs.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, configs.Clock(0), cfg.Start)
+
+	path = writeConfig(t, `{"laps": 2, "lapLen": 3500, "penaltyLen": 150, "firingLines": 2, "startDelta": "00:01:30"}`)
+	t.Setenv("BIATHLON_START", "00:00:00")
+
+	cfg, err = configs.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "00:00:00.000", cfg.Start.String())
+}
+
+func TestLoadConfigSpareRounds(t *testing.T) {
+	relay := func(spareRounds string) string {
+		return `{
+			"format": "relay",
+			"laps": 1,
+			"lapLen": 3000,
+			"penaltyLen": 150,
+			"firingLines": 1,
+			"start": "10:00:00",
+			"startDelta": "00:01:30",
+			"relay": {` + spareRounds + `"teams": [{"id": 1, "legs": [1, 2]}]}
+		}`
+	}
+
+	cfg, err := configs.LoadConfig(writeConfig(t, relay("")))
+	require.NoError(t, err)
+	assert.Equal(t, configs.DefaultSpareRounds, cfg.Relay.SpareRounds)
+
+	cfg, err = configs.LoadConfig(writeConfig(t, relay(`"spareRounds": 0,`)))
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Relay.SpareRounds, "zero spare rounds is a setting of its own")
+
+	cfg, err = configs.LoadConfig(writeConfig(t, relay(`"spareRounds": 2,`)))
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.Relay.SpareRounds)
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+laps: 2
+lapLen: 3500
+penaltyLen: 150
+firingLines: 2
+start: "09:30:00"
+startDelta: "00:00:30.500"
+skippedLoopPenalty: "00:02:00"
+`), 0600))
+
+	cfg, err := configs.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "09:30:00.000", cfg.Start.String())
+	assert.Equal(t, 30*time.Second+500*time.Millisecond, cfg.StartDelta.Duration())
+	assert.Equal(t, 2*time.Minute, cfg.SkippedLoopPenalty.Duration())
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("BIATHLON_START_DELTA", "00:00:45")
+
+	path := writeConfig(t, `{
+		"laps": 2,
+		"lapLen": 3500,
+		"penaltyLen": 150,
+		"firingLines": 2,
+		"start": "10:00:00.000",
+		"startDelta": "00:01:30"
+	}`)
+
+	cfg, err := configs.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Second, cfg.StartDelta.Duration())
+}
+
+func TestClockUnmarshalText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    configs.Clock
+		wantErr bool
+	}{
+		{text: "10:00:00.000", want: configs.Clock(10 * time.Hour)},
+		{text: "10:00:00", want: configs.Clock(10 * time.Hour)},
+		{text: "09:05:01.250", want: configs.Clock(9*time.Hour + 5*time.Minute + time.Second + 250*time.Millisecond)},
+		{text: "10:00", wantErr: true},
+		{text: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var c configs.Clock
+			err := c.UnmarshalText([]byte(tt.text))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c)
+		})
+	}
+}
+
+func TestDurationUnmarshalText(t *testing.T) {
+	var d configs.Duration
+	require.NoError(t, d.UnmarshalText([]byte("00:01:30")))
+	assert.Equal(t, 90*time.Second, d.Duration())
+
+	require.NoError(t, d.UnmarshalText([]byte("00:00:00.750")))
+	assert.Equal(t, 750*time.Millisecond, d.Duration())
+
+	require.NoError(t, d.UnmarshalText(nil))
+	assert.Equal(t, time.Duration(0), d.Duration())
+
+	require.Error(t, d.UnmarshalText([]byte("soon")))
+
+	text, err := configs.Duration(90 * time.Second).MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "00:01:30.000", string(text))
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	_, err := configs.LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+
+	path := writeConfig(t, `{
+		"format": "biathlon",
+		"laps": 0,
+		"lapLen": -1,
+		"penaltyLen": 150,
+		"firingLines": 3,
+		"startDelta": "00:01:30"
+	}`)
+
+	_, err = configs.LoadConfig(path)
+
+	var validationErr *configs.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+
+	var fields []string
+	for _, fieldErr := range validationErr.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.Equal(t, []string{"format", "laps", "lapLen", "firingLines", "start"}, fields)
+
+	path = writeConfig(t, `{"laps": 2, "lapLen": 3500, "firingLines": 2, "start": "10:00", "startDelta": "00:01:30"}`)
+	_, err = configs.LoadConfig(path)
+	require.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() *configs.Config {
+		return &configs.Config{
+			Format:        configs.FormatSprint,
+			Laps:          3,
+			LapLength:     3300,
+			PenaltyLength: 150,
+			FiringLines:   2,
+			ShotsPerLine:  5,
+			Start:         configs.Clock(10 * time.Hour),
+			StartDelta:    configs.Duration(30 * time.Second),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *configs.Config)
+		fields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(_ *configs.Config) {},
+		},
+		{
+			name: "lap lengths",
+			modify: func(cfg *configs.Config) {
+				cfg.LapLength = 0
+				cfg.LapLengths = []int{3300, -1}
+			},
+			fields: []string{"lapLengths", "lapLengths[1]"},
+		},
+		{
+			name: "shooting and penalties",
+			modify: func(cfg *configs.Config) {
+				cfg.PenaltyLength = 0
+				cfg.Shooting = []configs.FiringLine{{Position: "kneeling", Targets: 5, Shots: 4}}
+			},
+			fields: []string{"penaltyLen", "shooting", "shooting[0].position", "shooting[0].shots"},
+		},
+		{
+			name: "start times",
+			modify: func(cfg *configs.Config) {
+				cfg.Start = 0
+				cfg.StartDelta = 0
+			},
+			fields: []string{"startDelta"},
+		},
+		{
+			name: "relay teams",
+			modify: func(cfg *configs.Config) {
+				cfg.Format = configs.FormatRelay
+				cfg.Relay.Teams = []configs.Team{
+					{ID: 1, Legs: []int{1, 2, 2}},
+					{ID: 1, Legs: []int{3, 1}},
+				}
+			},
+			fields: []string{"relay.teams[0].legs", "relay.teams[1].id", "relay.teams[1].legs"},
+		},
+		{
+			name: "single mixed relay",
+			modify: func(cfg *configs.Config) {
+				cfg.Format = configs.FormatSingleMixedRelay
+				cfg.Relay.Teams = []configs.Team{
+					{ID: 1, Legs: []int{1, 2, 1, 2}},
+					{ID: 2, Legs: []int{3, 4, 4, 3}},
+				}
+			},
+			fields: []string{"relay.teams[1].legs"},
+		},
+		{
+			name: "tokens",
+			modify: func(cfg *configs.Config) {
+				cfg.Tokens = []configs.Token{
+					{Name: "gate", Token: "0123456789abcdef", Role: configs.RoleTiming},
+					{Name: "gate", Token: "0123456789abcdef", Role: configs.RoleJury},
+					{Name: "", Token: "short", Role: "admin"},
+				}
+			},
+			fields: []string{
+				"tokens[1].name", "tokens[1].token",
+				"tokens[2].name", "tokens[2].token", "tokens[2].role",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.fields == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var validationErr *configs.ValidationError
+			require.True(t, errors.As(err, &validationErr))
+
+			var fields []string
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, configs.RoleJury.Includes(configs.RoleTiming))
+	assert.True(t, configs.RoleTiming.Includes(configs.RoleViewer))
+	assert.True(t, configs.RoleViewer.Includes(configs.RoleViewer))
+	assert.False(t, configs.RoleViewer.Includes(configs.RoleTiming))
+	assert.False(t, configs.RoleTiming.Includes(configs.RoleJury))
+	assert.False(t, configs.Role("admin").Includes(configs.RoleViewer))
+	assert.False(t, configs.RoleJury.Includes(configs.Role("admin")))
+}
@@ -1,12 +1,12 @@
 package configs
 
 import (
+	"io"
 	"log/slog"
-	"os"
 )
 
-func ConfigureLogger() *slog.Logger {
+func ConfigureLogger(w io.Writer, level slog.Level) *slog.Logger {
 	return slog.New(
-		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}),
 	)
 }
@@ -0,0 +1,75 @@
+package configs
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock is a time of day, stored as the offset from midnight. It is read from
+// HH:MM:SS or HH:MM:SS.sss in JSON, YAML and environment variables.
+type Clock time.Duration
+
+func (c Clock) Time() time.Time {
+	return time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(c))
+}
+
+func (c Clock) String() string {
+	return c.Time().Format("15:04:05.000")
+}
+
+func (c Clock) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Clock) UnmarshalText(text []byte) error {
+	offset, err := parseClock(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid time of day %q", text)
+	}
+	*c = Clock(offset)
+	return nil
+}
+
+// Duration is a span of time written as HH:MM:SS or HH:MM:SS.sss.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return Clock(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = 0
+		return nil
+	}
+
+	offset, err := parseClock(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+	*d = Duration(offset)
+	return nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04:05.000", value)
+	if err != nil {
+		t, err = time.Parse("15:04:05", value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond()), nil
+}
@@ -0,0 +1,195 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		problems[i] = fieldErr.String()
+	}
+	return "invalid config: " + strings.Join(problems, "; ")
+}
+
+const minTokenLength = 16
+
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) check(ok bool, field string, format string, args ...any) {
+	if !ok {
+		v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Validate checks the race configuration and returns a *ValidationError with
+// every problem found, or nil.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	c.validateFormat(v)
+	c.validateCourse(v)
+	c.validateShooting(v)
+	c.validateTimes(v)
+
+	if c.Format.IsRelay() {
+		c.validateRelay(v)
+	}
+
+	c.validateTokens(v)
+
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+func (c *Config) validateFormat(v *validator) {
+	switch c.Format {
+	case FormatSprint, FormatIndividual, FormatPursuit, FormatMassStart,
+		FormatRelay, FormatMixedRelay, FormatSingleMixedRelay:
+	default:
+		v.check(false, "format", "unknown race format %q", c.Format)
+	}
+}
+
+func (c *Config) validateCourse(v *validator) {
+	v.check(c.Laps > 0, "laps", "must be positive, got %d", c.Laps)
+
+	if len(c.LapLengths) == 0 {
+		v.check(c.LapLength > 0, "lapLen", "must be positive, got %d", c.LapLength)
+	} else {
+		v.check(
+			len(c.LapLengths) == c.Laps,
+			"lapLengths",
+			"has %d entries, expected %d",
+			len(c.LapLengths),
+			c.Laps,
+		)
+		for i, length := range c.LapLengths {
+			v.check(length > 0, fmt.Sprintf("lapLengths[%d]", i), "must be positive, got %d", length)
+		}
+	}
+
+	if c.Format == FormatIndividual {
+		v.check(c.PenaltyLength >= 0, "penaltyLen", "must not be negative, got %d", c.PenaltyLength)
+	} else {
+		v.check(c.PenaltyLength > 0, "penaltyLen", "must be positive, got %d", c.PenaltyLength)
+	}
+}
+
+func (c *Config) validateShooting(v *validator) {
+	v.check(c.FiringLines >= 0, "firingLines", "must not be negative, got %d", c.FiringLines)
+	v.check(
+		c.FiringLines <= c.Laps,
+		"firingLines",
+		"%d firing lines do not fit in %d laps",
+		c.FiringLines,
+		c.Laps,
+	)
+	v.check(c.ShotsPerLine > 0, "shotsPerLine", "must be positive, got %d", c.ShotsPerLine)
+	v.check(c.PenaltySpeed >= 0, "penaltySpeed", "must not be negative, got %g", c.PenaltySpeed)
+
+	if len(c.Shooting) > 0 {
+		v.check(
+			len(c.Shooting) == c.FiringLines,
+			"shooting",
+			"has %d firing lines, expected %d",
+			len(c.Shooting),
+			c.FiringLines,
+		)
+	}
+
+	for i, line := range c.Shooting {
+		field := fmt.Sprintf("shooting[%d]", i)
+		v.check(
+			line.Position == "" || line.Position == "prone" || line.Position == "standing",
+			field+".position",
+			"must be prone or standing, got %q",
+			line.Position,
+		)
+		v.check(line.Targets > 0, field+".targets", "must be positive, got %d", line.Targets)
+		v.check(line.Shots >= line.Targets, field+".shots", "must be at least the number of targets, got %d", line.Shots)
+	}
+}
+
+// validateTimes checks that the start times are set, since a missing value
+// reads as midnight or as an empty start window. A start at midnight is fine
+// when it is given.
+func (c *Config) validateTimes(v *validator) {
+	v.check(!c.startMissing, "start", "is required")
+	v.check(c.StartDelta > 0, "startDelta", "must be positive, got %s", c.StartDelta)
+	v.check(c.SkippedLoopPenalty >= 0, "skippedLoopPenalty", "must not be negative, got %s", c.SkippedLoopPenalty)
+}
+
+func (c *Config) validateRelay(v *validator) {
+	v.check(len(c.Relay.Teams) > 0, "relay.teams", "a relay needs at least one team")
+	v.check(c.Relay.SpareRounds >= 0, "relay.spareRounds", "must not be negative, got %d", c.Relay.SpareRounds)
+
+	teams := make(map[int]bool)
+	athletes := make(map[int]int)
+	for i, team := range c.Relay.Teams {
+		field := fmt.Sprintf("relay.teams[%d]", i)
+		v.check(!teams[team.ID], field+".id", "duplicate team %d", team.ID)
+		teams[team.ID] = true
+
+		v.check(len(team.Legs) > 0, field+".legs", "a team needs at least one leg")
+
+		seen := make(map[int]bool)
+		for _, athlete := range team.Legs {
+			if seen[athlete] {
+				continue
+			}
+			seen[athlete] = true
+
+			other, exists := athletes[athlete]
+			v.check(!exists, field+".legs", "athlete %d already runs for team %d", athlete, other)
+			athletes[athlete] = team.ID
+		}
+
+		if c.Format == FormatSingleMixedRelay {
+			v.check(
+				len(team.Legs) == 4 && len(seen) == 2 && team.Legs[0] == team.Legs[2] && team.Legs[1] == team.Legs[3],
+				field+".legs",
+				"a single mixed relay alternates two athletes over four legs",
+			)
+		} else {
+			v.check(len(seen) == len(team.Legs), field+".legs", "every leg needs a different athlete")
+		}
+	}
+}
+
+func (c *Config) validateTokens(v *validator) {
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for i, token := range c.Tokens {
+		field := fmt.Sprintf("tokens[%d]", i)
+
+		v.check(token.Name != "", field+".name", "must not be empty")
+		v.check(!names[token.Name], field+".name", "duplicate token name %q", token.Name)
+		names[token.Name] = true
+
+		v.check(len(token.Token) >= minTokenLength, field+".token", "must be at least %d characters", minTokenLength)
+		v.check(!tokens[token.Token], field+".token", "is already used by another token")
+		tokens[token.Token] = true
+
+		v.check(token.Role.rank() > 0, field+".role", "must be viewer, timing or jury, got %q", token.Role)
+	}
+}
@@ -0,0 +1,289 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/logger/sl"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK      = 0
+	ExitFailure = 1 // the config, the events or an output file could not be read or written
+	ExitUsage   = 2 // unknown command or bad flags
+	ExitInvalid = 3 // the event stream failed validation
+)
+
+const usage = `Usage: biathlon [command] [flags]
+
+Commands:
+  run       process the events and write the output log and the results to a directory (default)
+  validate  check the events against the configuration and print the diagnostics
+  report    process the events and print the results
+  replay    process the events and print the output log as it is produced
+  follow    process a growing events file live, updating the output log and the results
+  serve     process a growing events file live and serve the standings over HTTP
+
+Run "biathlon <command> -h" for the flags of a command.
+`
+
+// ErrInvalidEvents is returned by the validate command when the event stream has errors.
+var ErrInvalidEvents = errors.New("invalid event stream")
+
+// UsageError is returned for an unknown command or bad flag values.
+type UsageError struct {
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	return e.Message
+}
+
+type app struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	log    *slog.Logger
+}
+
+// Run executes the command named by the first argument and returns the process
+// exit code. Without a command it runs "run", so the flags may come first.
+// Logs go to stderr, so stdout only carries the command output. Cancelling
+// ctx ends the follow and serve commands.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	a := &app{
+		ctx:    ctx,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		log:    configs.ConfigureLogger(stderr, slog.LevelInfo),
+	}
+
+	var err error
+	switch name {
+	case "run":
+		err = a.run(args)
+	case "validate":
+		err = a.validate(args)
+	case "report":
+		err = a.report(args)
+	case "replay":
+		err = a.replay(args)
+	case "follow":
+		err = a.follow(args)
+	case "serve":
+		err = a.serve(args)
+	case "help":
+		_, _ = fmt.Fprint(stdout, usage)
+	default:
+		_, _ = fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return ExitUsage
+	}
+
+	return a.exitCode(err)
+}
+
+func (a *app) exitCode(err error) int {
+	var usageErr *UsageError
+	var validationErr *utils.ValidationError
+
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return ExitOK
+	case errors.As(err, &usageErr):
+		if usageErr.Message != "" {
+			_, _ = fmt.Fprintln(a.stderr, usageErr.Message)
+		}
+		return ExitUsage
+	case errors.As(err, &validationErr), errors.Is(err, ErrInvalidEvents):
+		a.log.Error("validation failed", sl.Err(err))
+		return ExitInvalid
+	default:
+		a.log.Error("command failed", sl.Err(err))
+		return ExitFailure
+	}
+}
+
+// inputFlags are the flags shared by every command.
+type inputFlags struct {
+	config      string
+	events      string
+	inputFormat string
+}
+
+func (f *inputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.config, "config", "./config.json", "path to the race configuration")
+	fs.StringVar(&f.events, "events", "./events", `path to the events file, "-" for standard input`)
+	fs.StringVar(&f.inputFormat, "input-format", "auto", "events format: auto, text, jsonl or csv")
+}
+
+func (a *app) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	return fs
+}
+
+// parse parses the command flags. The flag package has already printed the
+// problem, so a parse error becomes an empty UsageError.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &UsageError{}
+	}
+	if fs.NArg() > 0 {
+		return &UsageError{Message: fmt.Sprintf("unexpected argument %q", fs.Arg(0))}
+	}
+	return nil
+}
+
+func (a *app) load(in inputFlags) (*configs.Config, []utils.Event, error) {
+	cfg, format, err := a.loadConfig(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, err := a.readEvents(in.events, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.log.Info("events loaded", slog.String("path", in.events), slog.Int("count", len(events)))
+
+	return cfg, events, nil
+}
+
+func (a *app) loadConfig(in inputFlags) (*configs.Config, utils.EventFormat, error) {
+	format, err := utils.ParseEventFormat(in.inputFormat)
+	if err != nil {
+		return nil, "", &UsageError{Message: err.Error()}
+	}
+
+	cfg, err := configs.LoadConfig(in.config)
+	if err != nil {
+		return nil, "", err
+	}
+	a.log.Info("config loaded", slog.String("path", in.config), slog.String("format", string(cfg.Format)))
+
+	return cfg, format, nil
+}
+
+func (a *app) readEvents(path string, format utils.EventFormat) ([]utils.Event, error) {
+	if path == "-" {
+		return utils.ReadEventsAs(a.stdin, format, "")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	return utils.ReadEventsAs(file, format, path)
+}
+
+// check validates the events and logs the diagnostics. In strict mode the
+// first error stops the command.
+func (a *app) check(cfg *configs.Config, events []utils.Event, strict bool) error {
+	diagnostics, err := utils.ValidateEvents(cfg, events, strict)
+	for _, diagnostic := range diagnostics {
+		a.log.Warn("invalid event", slog.String("diagnostic", diagnostic.String()))
+	}
+	return err
+}
+
+func process(cfg *configs.Config, events []utils.Event) *utils.Processor {
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+	processor.Close()
+
+	return processor
+}
+
+// parseFormats splits a comma-separated list of output formats.
+func parseFormats(value string) ([]report.Reporter, error) {
+	var reporters []report.Reporter
+	for _, format := range strings.Split(value, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" {
+			continue
+		}
+		reporter, err := newReporter(format)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, reporter)
+	}
+	if len(reporters) == 0 {
+		return nil, &UsageError{Message: "no output format given"}
+	}
+	return reporters, nil
+}
+
+// useTemplate makes the HTML reporters use the page template at path.
+func useTemplate(reporters []report.Reporter, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	tmpl, err := report.ParseHTMLTemplate(path)
+	if err != nil {
+		return err
+	}
+
+	for i, reporter := range reporters {
+		if _, ok := reporter.(report.HTMLReporter); ok {
+			reporters[i] = report.HTMLReporter{Template: tmpl}
+		}
+	}
+
+	return nil
+}
+
+func newReporter(format string) (report.Reporter, error) {
+	reporter, ok := report.New(format)
+	if !ok {
+		return nil, &UsageError{Message: fmt.Sprintf(
+			"unknown output format %q, expected one of %s", format, strings.Join(report.Names(), ", "),
+		)}
+	}
+	return reporter, nil
+}
+
+// writeEvents writes events in the text format of the incoming events, one per
+// line, so they can be read back with the event decoders.
+func writeEvents(w io.Writer, events []utils.Event) error {
+	for _, event := range events {
+		if _, err := fmt.Fprintln(w, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,490 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/lib/cli"
+)
+
+const testConfig = `{
+	"laps": 1,
+	"lapLen": 3000,
+	"penaltyLen": 150,
+	"firingLines": 1,
+	"start": "10:00:00.000",
+	"startDelta": "00:01:30"
+}`
+
+const testEvents = `[09:05:59.867] 1 1
+[09:15:00.841] 2 1 10:00:00.000
+[09:59:45.000] 3 1
+[10:00:01.744] 4 1
+[10:08:00.000] 5 1 1
+[10:08:01.000] 6 1 1
+[10:08:02.000] 6 1 2
+[10:08:03.000] 6 1 3
+[10:08:04.000] 6 1 4
+[10:08:05.000] 6 1 5
+[10:08:10.000] 7 1
+[10:15:00.000] 10 1
+`
+
+func writeInputs(t *testing.T, events string) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	eventsPath := filepath.Join(dir, "events")
+	require.NoError(t, os.WriteFile(configPath, []byte(testConfig), 0600))
+	require.NoError(t, os.WriteFile(eventsPath, []byte(events), 0600))
+
+	return configPath, eventsPath
+}
+
+func run(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunWritesOutputDirectory(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+	outDir := filepath.Join(t.TempDir(), "race")
+
+	code, _, _ := run("", "run", "-config", configPath, "-events", eventsPath, "-out", outDir)
+	require.Equal(t, cli.ExitOK, code)
+
+	output, err := os.ReadFile(filepath.Join(outDir, "output.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "The competitor(1) registered")
+	assert.Contains(t, string(output), "The competitor(1) has finished")
+
+	outgoing, err := os.ReadFile(filepath.Join(outDir, "outgoing.events"))
+	require.NoError(t, err)
+	assert.Equal(t, "[10:15:00.000] 33 1\n", string(outgoing))
+
+	result, err := os.ReadFile(filepath.Join(outDir, "result.txt"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(result), "[10:15:00.000] 1 "), string(result))
+}
+
+func TestRunWithoutCommand(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+	outDir := t.TempDir()
+
+	code, _, _ := run("", "-config", configPath, "-events", eventsPath, "-out", outDir)
+	require.Equal(t, cli.ExitOK, code)
+	assert.FileExists(t, filepath.Join(outDir, "result.txt"))
+}
+
+func TestReportFromStdin(t *testing.T) {
+	configPath, _ := writeInputs(t, testEvents)
+
+	code, stdout, stderr := run(testEvents, "report", "-config", configPath, "-events", "-")
+	require.Equal(t, cli.ExitOK, code)
+	assert.True(t, strings.HasPrefix(stdout, "[10:15:00.000] 1 "), stdout)
+	assert.Contains(t, stderr, "events loaded")
+}
+
+func TestReplayUntil(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+
+	code, stdout, _ := run("", "replay", "-config", configPath, "-events", eventsPath, "-until", "10:08:02")
+	require.Equal(t, cli.ExitOK, code)
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, lines, 7)
+	assert.Equal(t, "[10:08:02.000] The target(2) has been hit by competitor(1)", lines[len(lines)-1])
+}
+
+func TestValidate(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+
+	code, stdout, _ := run("", "validate", "-config", configPath, "-events", eventsPath)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Empty(t, stdout)
+
+	_, eventsPath = writeInputs(t, testEvents+"[10:00:00.000] 4 1\n")
+
+	code, stdout, _ = run("", "validate", "-config", configPath, "-events", eventsPath)
+	assert.Equal(t, cli.ExitInvalid, code)
+	assert.Contains(t, stdout, "out-of-order")
+}
+
+func TestStrictRunStopsAtInvalidEvent(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents+"[10:00:00.000] 4 1\n")
+	outDir := t.TempDir()
+
+	code, _, _ := run("", "run", "-strict", "-config", configPath, "-events", eventsPath, "-out", outDir)
+	assert.Equal(t, cli.ExitInvalid, code)
+	assert.NoFileExists(t, filepath.Join(outDir, "result.txt"))
+}
+
+func TestExitCodes(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "help", args: []string{"help"}, code: cli.ExitOK},
+		{name: "command help", args: []string{"report", "-h"}, code: cli.ExitOK},
+		{name: "unknown command", args: []string{"publish"}, code: cli.ExitUsage},
+		{name: "unknown flag", args: []string{"report", "-colour"}, code: cli.ExitUsage},
+		{name: "extra argument", args: []string{"report", "events"}, code: cli.ExitUsage},
+		{
+			name: "unknown format",
+			args: []string{"report", "-config", configPath, "-events", eventsPath, "-format", "pdf"},
+			code: cli.ExitUsage,
+		},
+		{name: "bad until", args: []string{"replay", "-until", "noon"}, code: cli.ExitUsage},
+		{
+			name: "missing config",
+			args: []string{"report", "-config", "missing.json", "-events", eventsPath},
+			code: cli.ExitFailure,
+		},
+		{
+			name: "missing events",
+			args: []string{"report", "-config", configPath, "-events", "missing"},
+			code: cli.ExitFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := run("", tt.args...)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestRunReplacesOrAppends(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+	outDir := t.TempDir()
+	resultPath := filepath.Join(outDir, "result.txt")
+
+	args := []string{"run", "-config", configPath, "-events", eventsPath, "-out", outDir}
+	code, _, _ := run("", args...)
+	require.Equal(t, cli.ExitOK, code)
+	first, err := os.ReadFile(resultPath)
+	require.NoError(t, err)
+
+	code, _, _ = run("", args...)
+	require.Equal(t, cli.ExitOK, code)
+	second, err := os.ReadFile(resultPath)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	code, _, _ = run("", append(args, "--append")...)
+	require.Equal(t, cli.ExitOK, code)
+	third, err := os.ReadFile(resultPath)
+	require.NoError(t, err)
+	assert.Equal(t, append(first, first...), third)
+}
+
+func TestRunWritesEveryFormat(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+	outDir := t.TempDir()
+
+	code, _, _ := run("", "run", "-config", configPath, "-events", eventsPath, "-out", outDir, "-format", "text,json,csv")
+	require.Equal(t, cli.ExitOK, code)
+
+	for _, name := range []string{"result.txt", "result.json", "result.csv"} {
+		assert.FileExists(t, filepath.Join(outDir, name))
+	}
+}
+
+func TestReportHTMLTemplate(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+	templatePath := filepath.Join(t.TempDir(), "page.html")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{{range .Competitors}}{{.Rank}}. {{.Bib}}{{end}}`), 0600))
+
+	args := []string{"report", "-config", configPath, "-events", eventsPath, "-format", "html"}
+	code, stdout, _ := run("", append(args, "-template", templatePath)...)
+	require.Equal(t, cli.ExitOK, code)
+	assert.Equal(t, "1. 1", stdout)
+
+	code, _, _ = run("", append(args, "-template", "missing.html")...)
+	assert.Equal(t, cli.ExitFailure, code)
+}
+
+func TestReportJSONLFromStdin(t *testing.T) {
+	configPath, _ := writeInputs(t, testEvents)
+
+	var input strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(testEvents), "\n") {
+		fields := strings.Fields(line)
+		params := ""
+		if len(fields) > 3 {
+			params = `, "params": "` + fields[3] + `"`
+		}
+		input.WriteString(`{"time": "` + fields[0] + `", "id": ` + fields[1] + `, "competitor": ` + fields[2] + params + "}\n")
+	}
+
+	code, stdout, _ := run(input.String(), "report", "-config", configPath, "-events", "-")
+	require.Equal(t, cli.ExitOK, code)
+	assert.True(t, strings.HasPrefix(stdout, "[10:15:00.000] 1 "), stdout)
+
+	code, _, _ = run(input.String(), "report", "-config", configPath, "-events", "-", "-input-format", "yaml")
+	assert.Equal(t, cli.ExitUsage, code)
+}
+
+func TestFollow(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, "")
+	outDir := t.TempDir()
+	lines := strings.SplitAfter(testEvents, "\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- cli.Run(ctx, []string{
+			"follow", "-config", configPath, "-events", eventsPath, "-out", outDir, "-interval", "5ms",
+		}, strings.NewReader(""), &stdout, &stderr)
+	}()
+
+	resultPath := filepath.Join(outDir, "result.txt")
+	waitFor := func(path, text string) {
+		t.Helper()
+		assert.Eventually(t, func() bool {
+			data, err := os.ReadFile(path)
+			return err == nil && strings.Contains(string(data), text)
+		}, 2*time.Second, 5*time.Millisecond, text)
+	}
+
+	appendEvents := func(data string) {
+		file, err := os.OpenFile(eventsPath, os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = file.WriteString(data)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+	}
+
+	appendEvents(strings.Join(lines[:4], ""))
+	waitFor(filepath.Join(outDir, "output.log"), "The competitor(1) has started")
+	waitFor(resultPath, "[00:00:00.000] 1 [{,}] [] 0/0")
+
+	appendEvents(strings.Join(lines[4:], ""))
+	waitFor(resultPath, "[10:15:00.000] 1 ")
+
+	cancel()
+	select {
+	case code := <-done:
+		assert.Equal(t, cli.ExitOK, code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("follow did not stop")
+	}
+	assert.Contains(t, stdout.String(), "The competitor(1) has finished")
+}
+
+func TestFollowDisqualifiesNoShowAtEndOfInput(t *testing.T) {
+	configPath, _ := writeInputs(t, "")
+	outDir := t.TempDir()
+	events := "[09:05:59.867] 1 2\n[09:15:00.841] 2 2 10:00:00.000\n[09:59:45.000] 3 2\n"
+
+	code, stdout, _ := run(events, "follow", "-config", configPath, "-events", "-", "-out", outDir)
+	require.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "[10:01:30.000] The competitor(2) is disqualified")
+
+	result, err := os.ReadFile(filepath.Join(outDir, "result.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(result), "[NotStarted]")
+	assert.NotContains(t, string(result), "[00:00:00.000] 2")
+
+	log, err := os.ReadFile(filepath.Join(outDir, "output.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(log), "The competitor(2) is disqualified")
+}
+
+func TestFollowInterruptedKeepsNoShows(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, "[09:05:59.867] 1 2\n[09:15:00.841] 2 2 10:00:00.000\n[09:59:45.000] 3 2\n")
+	outDir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- cli.Run(ctx, []string{
+			"follow", "-config", configPath, "-events", eventsPath, "-out", outDir, "-interval", "5ms",
+		}, strings.NewReader(""), &stdout, &stderr)
+	}()
+
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(filepath.Join(outDir, "output.log"))
+		return err == nil && strings.Contains(string(data), "The competitor(2) is on the start line")
+	}, 2*time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case code := <-done:
+		assert.Equal(t, cli.ExitOK, code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("follow did not stop")
+	}
+	assert.NotContains(t, stdout.String(), "disqualified")
+
+	result, err := os.ReadFile(filepath.Join(outDir, "result.txt"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(result), "[NotStarted]")
+}
+
+// serveRace runs the serve command until stop is called, which returns its
+// exit code and logs.
+type serveRace struct {
+	addr   string
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan int
+	stderr bytes.Buffer
+}
+
+func startServe(t *testing.T, args ...string) *serveRace {
+	t.Helper()
+
+	return startServeInput(t, "", args...)
+}
+
+// startServeInput is startServe with stdin as the standard input.
+func startServeInput(t *testing.T, stdin string, args ...string) *serveRace {
+	t.Helper()
+
+	var listenConfig net.ListenConfig
+	listener, err := listenConfig.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &serveRace{
+		addr: addr,
+		// Without keep-alives no spare connection keeps the shutdown waiting.
+		client: &http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+		cancel: cancel,
+		done:   make(chan int),
+	}
+	args = append([]string{"serve", "-addr", addr, "-interval", "5ms"}, args...)
+	go func() {
+		var stdout bytes.Buffer
+		s.done <- cli.Run(ctx, args, strings.NewReader(stdin), &stdout, &s.stderr)
+	}()
+
+	return s
+}
+
+func (s *serveRace) get(path string) string {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://"+s.addr+path, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
+
+func (s *serveRace) post(t *testing.T, events string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://"+s.addr+"/events",
+		strings.NewReader(events))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := s.client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func (s *serveRace) stop(t *testing.T) (int, string) {
+	t.Helper()
+
+	s.cancel()
+	select {
+	case code := <-s.done:
+		return code, s.stderr.String()
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not stop")
+		return 0, ""
+	}
+}
+
+func TestServe(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+
+	s := startServe(t, "-config", configPath, "-events", eventsPath)
+	assert.Eventually(t, func() bool {
+		return strings.HasPrefix(s.get("/standings?format=text"), "[10:15:00.000] 1 ")
+	}, 2*time.Second, 5*time.Millisecond)
+
+	s.post(t, "[10:20:00.000] 1 2\n")
+
+	code, _ := s.stop(t)
+	assert.Equal(t, cli.ExitOK, code)
+}
+
+func TestServeFinishesStandardInput(t *testing.T) {
+	configPath, _ := writeInputs(t, "")
+	events := "[09:05:59.867] 1 2\n[09:15:00.841] 2 2 10:00:00.000\n"
+
+	s := startServeInput(t, events, "-config", configPath, "-events", "-")
+	assert.Eventually(t, func() bool {
+		return strings.Contains(s.get("/log"), "The competitor(2) is disqualified")
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Contains(t, s.get("/standings"), `"status": "not-started"`)
+
+	code, _ := s.stop(t)
+	assert.Equal(t, cli.ExitOK, code)
+}
+
+func TestServeRestoresJournal(t *testing.T) {
+	configPath, eventsPath := writeInputs(t, testEvents)
+	journalPath := filepath.Join(t.TempDir(), "race.journal")
+	args := []string{"-config", configPath, "-events", eventsPath, "-journal", journalPath}
+
+	s := startServe(t, args...)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(s.get("/log"), "The competitor(1) has finished")
+	}, 2*time.Second, 5*time.Millisecond)
+	s.post(t, "[10:20:00.000] 1 2\n")
+	first := s.get("/log")
+
+	code, _ := s.stop(t)
+	require.Equal(t, cli.ExitOK, code)
+
+	s = startServe(t, append(args, "-fsync", "interval")...)
+	assert.Eventually(t, func() bool {
+		return s.get("/log") == first
+	}, 2*time.Second, 5*time.Millisecond)
+
+	code, logs := s.stop(t)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, logs, "journal restored")
+	assert.Contains(t, logs, "skipping journaled events")
+	assert.NotContains(t, logs, "event rejected")
+	assert.Equal(t, 1, strings.Count(first, "The competitor(2) registered"))
+}
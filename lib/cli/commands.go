@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/output"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// run processes the events and writes the output log, the outgoing events
+// and one results file per requested format to the output directory.
+func (a *app) run(args []string) error {
+	var in inputFlags
+	var outDir, format, templatePath string
+	var strict, appendOutput bool
+
+	fs := a.newFlagSet("run")
+	in.register(fs)
+	fs.StringVar(&outDir, "out", ".", "directory for output.log and the results files")
+	fs.BoolVar(&appendOutput, "append", false, "append to existing output files instead of replacing them")
+	fs.StringVar(&format, "format", "text", "comma-separated list of results formats: "+strings.Join(report.Names(), ", "))
+	fs.StringVar(&templatePath, "template", "", "HTML results page template, the built-in page by default")
+	fs.BoolVar(&strict, "strict", false, "stop at the first invalid event")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	reporters, err := parseFormats(format)
+	if err != nil {
+		return err
+	}
+	if err := useTemplate(reporters, templatePath); err != nil {
+		return err
+	}
+
+	cfg, events, err := a.load(in)
+	if err != nil {
+		return err
+	}
+	if err := a.check(cfg, events, strict); err != nil {
+		return err
+	}
+
+	processor := process(cfg, events)
+
+	mode := output.ModeReplace
+	if appendOutput {
+		mode = output.ModeAppend
+	}
+
+	if err := os.MkdirAll(outDir, 0750); err != nil {
+		return fmt.Errorf("cannot create output directory: %w", err)
+	}
+
+	outputPath := filepath.Join(outDir, "output.log")
+	err = output.WriteFile(outputPath, mode, func(w io.Writer) error {
+		return writeLines(w, processor.Output())
+	})
+	if err != nil {
+		return err
+	}
+
+	outgoingPath := filepath.Join(outDir, "outgoing.events")
+	err = output.WriteFile(outgoingPath, mode, func(w io.Writer) error {
+		return writeEvents(w, processor.Outgoing())
+	})
+	if err != nil {
+		return err
+	}
+
+	standings := report.NewStandings(cfg, processor)
+	for _, reporter := range reporters {
+		name := "result." + reporter.Extension()
+		err := output.WriteFile(filepath.Join(outDir, name), mode, func(w io.Writer) error {
+			return reporter.Report(w, standings)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	a.log.Info("finish processing events", slog.String("out", outDir))
+
+	return nil
+}
+
+// validate prints every diagnostic for the event stream. It fails when there
+// are errors, or warnings with -fail-on-warning.
+func (a *app) validate(args []string) error {
+	var in inputFlags
+	var failOnWarning bool
+
+	fs := a.newFlagSet("validate")
+	in.register(fs)
+	fs.BoolVar(&failOnWarning, "fail-on-warning", false, "treat warnings as failures")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, events, err := a.load(in)
+	if err != nil {
+		return err
+	}
+
+	diagnostics, _ := utils.ValidateEvents(cfg, events, false)
+
+	var errs, warnings int
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == utils.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+		if _, err := fmt.Fprintln(a.stdout, diagnostic); err != nil {
+			return err
+		}
+	}
+
+	if errs > 0 || (failOnWarning && warnings > 0) {
+		return fmt.Errorf("%w: %d errors, %d warnings", ErrInvalidEvents, errs, warnings)
+	}
+
+	return nil
+}
+
+// report processes the events and prints the results.
+func (a *app) report(args []string) error {
+	var in inputFlags
+	var format, templatePath string
+	var strict bool
+
+	fs := a.newFlagSet("report")
+	in.register(fs)
+	fs.StringVar(&format, "format", "text", "results format: "+strings.Join(report.Names(), ", "))
+	fs.StringVar(&templatePath, "template", "", "HTML results page template, the built-in page by default")
+	fs.BoolVar(&strict, "strict", false, "stop at the first invalid event")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	reporter, err := newReporter(format)
+	if err != nil {
+		return err
+	}
+	reporters := []report.Reporter{reporter}
+	if err := useTemplate(reporters, templatePath); err != nil {
+		return err
+	}
+	reporter = reporters[0]
+
+	cfg, events, err := a.load(in)
+	if err != nil {
+		return err
+	}
+	if err := a.check(cfg, events, strict); err != nil {
+		return err
+	}
+
+	return reporter.Report(a.stdout, report.NewStandings(cfg, process(cfg, events)))
+}
+
+// replay feeds the events to a processor one by one and prints the output log
+// as it is produced. With -until it stops at the given time of day, as if the
+// race were still running.
+func (a *app) replay(args []string) error {
+	var in inputFlags
+	var until string
+	var strict bool
+
+	fs := a.newFlagSet("replay")
+	in.register(fs)
+	fs.StringVar(&until, "until", "", "stop at this time of day, HH:MM:SS or HH:MM:SS.sss")
+	fs.BoolVar(&strict, "strict", false, "stop at the first invalid event")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	var stop configs.Clock
+	if until != "" {
+		if err := stop.UnmarshalText([]byte(until)); err != nil {
+			return &UsageError{Message: err.Error()}
+		}
+	}
+
+	cfg, events, err := a.load(in)
+	if err != nil {
+		return err
+	}
+	if err := a.check(cfg, events, strict); err != nil {
+		return err
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		if until != "" && eventAfter(event, stop.Time()) {
+			break
+		}
+		if err := writeLines(a.stdout, processor.Apply(event)); err != nil {
+			return err
+		}
+	}
+
+	if until != "" {
+		return writeLines(a.stdout, processor.Advance(stop.Time()))
+	}
+	return writeLines(a.stdout, processor.Close())
+}
+
+func eventAfter(event utils.Event, t time.Time) bool {
+	raw := event.RawTime
+	if len(raw) < 2 {
+		return false
+	}
+
+	eventTime, err := utils.ParseTime(raw[1 : len(raw)-1])
+	return err == nil && eventTime.After(t)
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/follow"
+	"biathlon-competitions-prototype/lib/output"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// follow reads a growing events file during a live race. Every output log
+// line is printed and appended to output.log as soon as its event arrives,
+// and the results files are replaced after every event. It runs until the
+// context is cancelled, or until standard input ends with -events -. At the
+// end of standard input competitors whose start window expired are
+// disqualified; an interrupted file leaves the standings as they are.
+func (a *app) follow(args []string) error {
+	var in inputFlags
+	var outDir, format, templatePath string
+	var appendOutput bool
+	var interval time.Duration
+
+	fs := a.newFlagSet("follow")
+	in.register(fs)
+	fs.StringVar(&outDir, "out", ".", "directory for output.log and the results files")
+	fs.BoolVar(&appendOutput, "append", false, "append to an existing output.log instead of starting a new one")
+	fs.StringVar(&format, "format", "text", "comma-separated list of results formats: "+strings.Join(report.Names(), ", "))
+	fs.StringVar(&templatePath, "template", "", "HTML results page template, the built-in page by default")
+	fs.DurationVar(&interval, "interval", follow.DefaultInterval, "how often to check the events file for new data")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	reporters, err := parseFormats(format)
+	if err != nil {
+		return err
+	}
+	if err := useTemplate(reporters, templatePath); err != nil {
+		return err
+	}
+
+	cfg, eventFormat, err := a.loadConfig(in)
+	if err != nil {
+		return err
+	}
+
+	source, err := a.openFollow(in.events, interval)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+
+	decoder, err := utils.NewEventDecoder(source, eventFormat, in.events)
+	if err != nil {
+		return &UsageError{Message: err.Error()}
+	}
+
+	if err := os.MkdirAll(outDir, 0750); err != nil {
+		return fmt.Errorf("cannot create output directory: %w", err)
+	}
+
+	live, err := newLiveRace(cfg, outDir, appendOutput, reporters)
+	if err != nil {
+		return err
+	}
+	defer live.log.Abort()
+
+	a.log.Info("following events", slog.String("path", in.events), slog.String("out", outDir))
+
+	for {
+		event, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("cannot parse events: %w", err)
+		}
+
+		if err := a.applyLive(live, event); err != nil {
+			return err
+		}
+	}
+
+	// A followed file only ends when the command is interrupted and the race
+	// may still be running, so only the end of standard input closes it.
+	if in.events == "-" {
+		if err := a.publishLive(live, live.processor.Close()); err != nil {
+			return err
+		}
+	}
+
+	a.log.Info("stopped following events", slog.Int("count", live.count))
+
+	return live.log.Commit()
+}
+
+func (a *app) openFollow(path string, interval time.Duration) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(a.stdin), nil
+	}
+	return follow.Open(a.ctx, path, interval)
+}
+
+// liveRace is the state of the follow command.
+type liveRace struct {
+	cfg         *configs.Config
+	outDir      string
+	reporters   []report.Reporter
+	validator   *utils.Validator
+	processor   *utils.Processor
+	log         *output.Writer
+	diagnostics int
+	count       int
+}
+
+func newLiveRace(cfg *configs.Config, outDir string, appendOutput bool, reporters []report.Reporter) (*liveRace, error) {
+	logPath := filepath.Join(outDir, "output.log")
+	if !appendOutput {
+		err := output.WriteFile(logPath, output.ModeReplace, func(io.Writer) error { return nil })
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log, err := output.Create(logPath, output.ModeAppend)
+	if err != nil {
+		return nil, err
+	}
+
+	live := &liveRace{
+		cfg:       cfg,
+		outDir:    outDir,
+		reporters: reporters,
+		validator: utils.NewValidator(cfg, false),
+		processor: utils.NewProcessor(cfg),
+		log:       log,
+	}
+
+	if err := live.writeResults(); err != nil {
+		log.Abort()
+		return nil, err
+	}
+
+	return live, nil
+}
+
+// applyLive processes one event and publishes the lines and standings it produced.
+func (a *app) applyLive(live *liveRace, event utils.Event) error {
+	live.count++
+
+	_ = live.validator.Check(event)
+	diagnostics := live.validator.Diagnostics()
+	for _, diagnostic := range diagnostics[live.diagnostics:] {
+		a.log.Warn("invalid event", slog.String("diagnostic", diagnostic.String()))
+	}
+	live.diagnostics = len(diagnostics)
+
+	return a.publishLive(live, live.processor.Apply(event))
+}
+
+// publishLive prints and logs the output lines and replaces the results files.
+func (a *app) publishLive(live *liveRace, lines []string) error {
+	if err := writeLines(a.stdout, lines); err != nil {
+		return err
+	}
+	if err := writeLines(live.log, lines); err != nil {
+		return fmt.Errorf("cannot write output log: %w", err)
+	}
+	if err := live.log.Flush(); err != nil {
+		return fmt.Errorf("cannot write output log: %w", err)
+	}
+
+	return live.writeResults()
+}
+
+func (live *liveRace) writeResults() error {
+	standings := report.NewStandings(live.cfg, live.processor)
+	for _, reporter := range live.reporters {
+		name := filepath.Join(live.outDir, "result."+reporter.Extension())
+		err := output.WriteFile(name, output.ModeReplace, func(w io.Writer) error {
+			return reporter.Report(w, standings)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
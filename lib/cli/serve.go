@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"biathlon-competitions-prototype/lib/follow"
+	"biathlon-competitions-prototype/lib/journal"
+	"biathlon-competitions-prototype/lib/logger/sl"
+	"biathlon-competitions-prototype/lib/race"
+	"biathlon-competitions-prototype/lib/server"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// journalFlags are the flags of the event journal.
+type journalFlags struct {
+	path     string
+	fsync    string
+	interval time.Duration
+}
+
+func (f *journalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "journal", "", "append accepted events to this journal and restore the race from it")
+	fs.StringVar(&f.fsync, "fsync", string(journal.SyncAlways), "when to sync the journal: always, interval or never")
+	fs.DurationVar(&f.interval, "fsync-interval", journal.DefaultSyncInterval, "how often to sync with -fsync interval")
+}
+
+// serve runs the HTTP API of a live race. Events are submitted over HTTP and
+// read from a growing file like the follow command does, or from standard
+// input with -events -. An empty -events only takes submitted events. With
+// -journal the race survives a restart. It runs until the context is
+// cancelled.
+func (a *app) serve(args []string) error {
+	var in inputFlags
+	var journalIn journalFlags
+	var addr string
+	var interval time.Duration
+
+	fs := a.newFlagSet("serve")
+	in.register(fs)
+	journalIn.register(fs)
+	fs.StringVar(&addr, "addr", ":8080", "address to listen on")
+	fs.DurationVar(&interval, "interval", follow.DefaultInterval, "how often to check the events file for new data")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	policy, err := journal.ParseSyncPolicy(journalIn.fsync)
+	if err != nil {
+		return &UsageError{Message: err.Error()}
+	}
+
+	cfg, eventFormat, err := a.loadConfig(in)
+	if err != nil {
+		return err
+	}
+
+	r := race.New(cfg, a.log)
+	if journalIn.path != "" {
+		j, err := a.restore(r, journalIn, policy)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := j.Close(); err != nil {
+				a.log.Error("cannot close the journal", sl.Err(err))
+			}
+		}()
+	}
+
+	var source io.ReadCloser
+	if in.events != "" {
+		source, err = a.openFollow(in.events, interval)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = source.Close() }()
+	}
+
+	var listenConfig net.ListenConfig
+	listener, err := listenConfig.Listen(a.ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot listen: %w", err)
+	}
+
+	if len(cfg.Tokens) == 0 {
+		a.log.Warn("no API tokens configured, anyone can submit events")
+	}
+
+	srv := &http.Server{
+		Handler:           server.New(r, cfg.Tokens, a.log),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(listener) }()
+	a.log.Info("serving", slog.String("addr", listener.Addr().String()), slog.String("events", in.events))
+
+	fed := make(chan error, 1)
+	if source != nil {
+		go func() { fed <- a.feed(r, source, eventFormat, in.events) }()
+	}
+
+	for done := false; !done; {
+		select {
+		case err = <-served:
+			done = true
+		case err = <-fed:
+			fed, done = nil, err != nil
+		case <-a.ctx.Done():
+			done = true
+		}
+	}
+
+	r.Close()
+	// A file feed ends with the context. A read from standard input cannot be
+	// interrupted, so that feed is left behind.
+	if source != nil && fed != nil && in.events != "-" {
+		if feedErr := <-fed; err == nil {
+			err = feedErr
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(ctx); shutdownErr != nil {
+		a.log.Warn("cannot shut down the server gracefully", sl.Err(shutdownErr))
+		_ = srv.Close()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// feed applies the decoded events to the race until the input ends. Events
+// that fail validation are logged and skipped. Standard input may end before
+// the server is stopped, the race is then finished and keeps taking submitted
+// events. A followed file only ends on shutdown and leaves the race as it is.
+func (a *app) feed(r *race.Race, source io.Reader, format utils.EventFormat, path string) error {
+	decoder, err := utils.NewEventDecoder(source, format, path)
+	if err != nil {
+		return &UsageError{Message: err.Error()}
+	}
+
+	skip := r.FeedPosition()
+	if skip > 0 {
+		a.log.Info("skipping journaled events", slog.String("path", path), slog.Int("count", skip))
+	}
+
+	position := 0
+	for {
+		event, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			a.log.Info("events feed ended", slog.String("path", path), slog.Int("count", position))
+			if path != "-" {
+				return nil
+			}
+			if _, err := r.Finish(); err != nil && !errors.Is(err, race.ErrClosed) {
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot parse events: %w", err)
+		}
+
+		position++
+		if position <= skip {
+			continue
+		}
+
+		_, err = r.ApplyFeed(position, event)
+		var validationErr *utils.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			a.log.Warn("event rejected", sl.Err(err))
+		case errors.Is(err, race.ErrClosed):
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// restore opens the journal, replays it into the race and has the race
+// journal the events it accepts from now on.
+func (a *app) restore(r *race.Race, in journalFlags, policy journal.SyncPolicy) (*journal.Journal, error) {
+	path := in.path
+	j, recovery, err := journal.Open(path, policy, in.interval)
+	if err != nil {
+		return nil, err
+	}
+
+	if tail := recovery.Tail; tail != nil {
+		a.log.Error("dropped the corrupt end of the journal",
+			slog.String("path", path),
+			slog.Int64("offset", tail.Offset),
+			slog.Int64("bytes", tail.Size),
+			slog.String("reason", tail.Reason),
+			slog.String("saved", tail.Saved),
+		)
+	}
+
+	restored := r.Restore(recovery.Records)
+	a.log.Info("journal restored",
+		slog.String("path", path),
+		slog.Int("records", len(recovery.Records)),
+		slog.Int("restored", restored),
+		slog.String("fsync", string(policy)),
+	)
+
+	r.UseJournal(j)
+	return j, nil
+}
@@ -0,0 +1,152 @@
+package follow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	DefaultInterval = 250 * time.Millisecond
+	chunkSize       = 32 * 1024
+)
+
+// Reader reads a file that is still being written, like tail -f. It only
+// returns complete lines, waits for more data at the end of the file and
+// follows truncation and rotation. Read returns io.EOF once the context is
+// done.
+type Reader struct {
+	ctx      context.Context
+	path     string
+	interval time.Duration
+
+	file    *os.File
+	offset  int64
+	partial []byte
+	ready   []byte
+	chunk   []byte
+}
+
+// Open starts following the file at path from its beginning. The file must
+// exist. A zero interval uses DefaultInterval.
+func Open(ctx context.Context, path string, interval time.Duration) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Reader{
+		ctx:      ctx,
+		path:     path,
+		interval: interval,
+		file:     file,
+		chunk:    make([]byte, chunkSize),
+	}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.ready) == 0 {
+		if err := r.ctx.Err(); err != nil {
+			return 0, io.EOF
+		}
+
+		n, err := r.file.Read(r.chunk)
+		if n > 0 {
+			r.offset += int64(n)
+			r.partial = append(r.partial, r.chunk[:n]...)
+			if i := bytes.LastIndexByte(r.partial, '\n'); i >= 0 {
+				r.ready = append(r.ready, r.partial[:i+1]...)
+				r.partial = append(r.partial[:0], r.partial[i+1:]...)
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		if err := r.reopen(); err != nil {
+			return 0, err
+		}
+		if len(r.ready) == 0 {
+			r.wait()
+		}
+	}
+
+	n := copy(p, r.ready)
+	r.ready = r.ready[n:]
+	return n, nil
+}
+
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// reopen is called at the end of the file. A file that shrank was truncated
+// and is read again from the start. When another file was moved to the path
+// the old one is complete, so its unterminated last line is released before
+// switching.
+func (r *Reader) reopen() error {
+	current, err := r.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if current.Size() < r.offset {
+		if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r.offset = 0
+		r.partial = r.partial[:0]
+		return nil
+	}
+
+	file := r.rotated(current)
+	if file == nil {
+		return nil
+	}
+	_ = r.file.Close()
+
+	if len(r.partial) > 0 {
+		r.ready = append(r.ready, r.partial...)
+		r.ready = append(r.ready, '\n')
+		r.partial = r.partial[:0]
+	}
+	r.file = file
+	r.offset = 0
+
+	return nil
+}
+
+// rotated opens the file now at the path if it is not the one being read. It
+// returns nil while the path is missing, e.g. between the rename and the
+// creation of the new file.
+func (r *Reader) rotated(current os.FileInfo) *os.File {
+	latest, err := os.Stat(r.path)
+	if err != nil || os.SameFile(current, latest) {
+		return nil
+	}
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		return nil
+	}
+	return file
+}
+
+func (r *Reader) wait() {
+	timer := time.NewTimer(r.interval)
+	defer timer.Stop()
+
+	select {
+	case <-r.ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,136 @@
+package follow_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/lib/follow"
+)
+
+const interval = 5 * time.Millisecond
+
+func appendTo(t *testing.T, path string, data string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+// readLines reads lines from the reader in the background.
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+func next(t *testing.T, lines <-chan string) string {
+	t.Helper()
+
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a line")
+		return ""
+	}
+}
+
+func noLine(t *testing.T, lines <-chan string) {
+	t.Helper()
+
+	select {
+	case line := <-lines:
+		t.Fatalf("unexpected line %q", line)
+	case <-time.After(10 * interval):
+	}
+}
+
+func TestReaderFollowsAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events")
+	appendTo(t, path, "first\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, err := follow.Open(ctx, path, interval)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	lines := readLines(reader)
+	assert.Equal(t, "first", next(t, lines))
+
+	appendTo(t, path, "sec")
+	noLine(t, lines)
+	appendTo(t, path, "ond\nthird\n")
+	assert.Equal(t, "second", next(t, lines))
+	assert.Equal(t, "third", next(t, lines))
+
+	cancel()
+	_, open := <-lines
+	assert.False(t, open, "the reader must end when the context is done")
+}
+
+func TestReaderFollowsTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events")
+	appendTo(t, path, "old line one\nold line two\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, err := follow.Open(ctx, path, interval)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	lines := readLines(reader)
+	assert.Equal(t, "old line one", next(t, lines))
+	assert.Equal(t, "old line two", next(t, lines))
+
+	require.NoError(t, os.WriteFile(path, []byte("new\n"), 0600))
+	assert.Equal(t, "new", next(t, lines))
+}
+
+func TestReaderFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events")
+	appendTo(t, path, "before\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, err := follow.Open(ctx, path, interval)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	lines := readLines(reader)
+	assert.Equal(t, "before", next(t, lines))
+
+	appendTo(t, path, "unterminated")
+	require.NoError(t, os.Rename(path, filepath.Join(dir, "events.1")))
+	noLine(t, lines)
+
+	appendTo(t, path, "after\n")
+	assert.Equal(t, "unterminated", next(t, lines))
+	assert.Equal(t, "after", next(t, lines))
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	_, err := follow.Open(context.Background(), filepath.Join(t.TempDir(), "missing"), interval)
+	require.Error(t, err)
+}
@@ -0,0 +1,402 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// SyncPolicy is when appended records are flushed to stable storage.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every record, so an accepted event is never lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs at most once per interval, losing at most the last
+	// interval of events on a power failure.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+const (
+	DefaultSyncInterval = time.Second
+
+	magic          = "BIATHLON-JOURNAL-1\n"
+	headerSize     = 8
+	maxPayloadSize = 64 * 1024
+	corruptSuffix  = ".corrupt"
+)
+
+// ParseSyncPolicy checks the name of a sync policy.
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(name); policy {
+	case SyncAlways, SyncInterval, SyncNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown sync policy %q, expected always, interval or never", name)
+	}
+}
+
+// Record is an accepted event. Feed is the 1-based position of the event in
+// the events file it was read from, or 0 for an event submitted over the API.
+type Record struct {
+	Feed  int
+	Event utils.Event
+}
+
+// CorruptTailError describes the records at the end of a journal that could
+// not be read, usually the last write before a crash. Open appends them to the
+// file at Saved and truncates the journal at Offset.
+type CorruptTailError struct {
+	Offset int64
+	Size   int64
+	Reason string
+	Saved  string
+}
+
+func (e *CorruptTailError) Error() string {
+	return fmt.Sprintf("corrupt journal tail at offset %d (%d bytes): %s", e.Offset, e.Size, e.Reason)
+}
+
+// Recovery is what Open read back from an existing journal.
+type Recovery struct {
+	Records []Record
+	// Tail is set when the end of the journal was damaged and dropped.
+	Tail *CorruptTailError
+}
+
+// Journal is an append-only file of checksummed records. Each record is a
+// big-endian uint32 payload length, the CRC-32C of the payload and the
+// payload: a JSON object with the feed position and the event. It is safe for
+// concurrent use.
+type Journal struct {
+	mu       sync.Mutex
+	file     *os.File
+	policy   SyncPolicy
+	interval time.Duration
+	lastSync time.Time
+	dirty    bool
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+func crcTable() *crc32.Table {
+	return crc32.MakeTable(crc32.Castagnoli)
+}
+
+// Open opens the journal at path, creating it if needed, and reads back every
+// record. A damaged tail is reported in the Recovery, saved next to the
+// journal and cut off, so new records follow the last good one. A record with
+// a valid checksum that cannot be read is an error and the file is left as it
+// is. A zero interval uses DefaultSyncInterval.
+func Open(path string, policy SyncPolicy, interval time.Duration) (*Journal, *Recovery, error) {
+	if _, err := ParseSyncPolicy(string(policy)); err != nil {
+		return nil, nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open journal: %w", err)
+	}
+
+	recovery, err := load(file, path)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	j := &Journal{
+		file:     file,
+		policy:   policy,
+		interval: interval,
+		lastSync: time.Now(),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if policy == SyncInterval {
+		go j.syncLoop()
+	} else {
+		close(j.stopped)
+	}
+
+	return j, recovery, nil
+}
+
+// load reads the records of the file, writes the magic of a new file and
+// truncates a damaged tail. The file is left positioned at its end.
+func load(file *os.File, path string) (*Recovery, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read journal: %w", err)
+	}
+
+	// A crash while a new journal was created can leave part of the magic.
+	if len(data) < len(magic) && bytes.HasPrefix([]byte(magic), data) {
+		return &Recovery{}, create(file, path)
+	}
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, fmt.Errorf("%s is not a journal", path)
+	}
+
+	recovery := &Recovery{}
+	offset := len(magic)
+	for offset < len(data) {
+		payload, size, reason := decode(data[offset:])
+		if reason != "" {
+			recovery.Tail = &CorruptTailError{
+				Offset: int64(offset),
+				Size:   int64(len(data) - offset),
+				Reason: reason,
+				Saved:  path + corruptSuffix,
+			}
+			break
+		}
+
+		// The checksum matched, so the record was written like this: it is a
+		// bug, not a damaged tail, and the records after it must not be dropped.
+		record, err := unmarshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read journal record at offset %d: %w", offset, err)
+		}
+
+		recovery.Records = append(recovery.Records, record)
+		offset += size
+	}
+
+	if recovery.Tail != nil {
+		if err := save(recovery.Tail.Saved, data[offset:]); err != nil {
+			return nil, fmt.Errorf("cannot save corrupt journal tail: %w", err)
+		}
+		if err := file.Truncate(int64(offset)); err != nil {
+			return nil, fmt.Errorf("cannot truncate journal: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			return nil, fmt.Errorf("cannot truncate journal: %w", err)
+		}
+	}
+
+	if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("cannot read journal: %w", err)
+	}
+
+	return recovery, nil
+}
+
+// save appends a damaged tail to the file at path, so earlier ones are kept.
+func save(path string, tail []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(tail); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// create writes the magic of a new journal and syncs its directory, so the
+// file itself survives a crash.
+func create(file *os.File, path string) error {
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("cannot write journal: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(magic), 0); err != nil {
+		return fmt.Errorf("cannot write journal: %w", err)
+	}
+	if _, err := file.Seek(int64(len(magic)), io.SeekStart); err != nil {
+		return fmt.Errorf("cannot write journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync journal: %w", err)
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// decode reads the frame of the record at the start of data. It returns the
+// payload and the size of the record, or the reason it cannot be read.
+func decode(data []byte) ([]byte, int, string) {
+	if len(data) < headerSize {
+		return nil, 0, "truncated record header"
+	}
+
+	length := binary.BigEndian.Uint32(data[0:4])
+	checksum := binary.BigEndian.Uint32(data[4:8])
+	if length == 0 || length > maxPayloadSize {
+		return nil, 0, fmt.Sprintf("invalid record length %d", length)
+	}
+	if len(data) < headerSize+int(length) {
+		return nil, 0, "truncated record"
+	}
+
+	payload := data[headerSize : headerSize+int(length)]
+	if crc32.Checksum(payload, crcTable()) != checksum {
+		return nil, 0, "checksum mismatch"
+	}
+
+	return payload, headerSize + int(length), ""
+}
+
+// payload is the JSON form of a record. The event fields are kept verbatim, so
+// an event reads back exactly as it was accepted.
+type payload struct {
+	Feed         int    `json:"feed"`
+	Time         string `json:"time"`
+	ID           int    `json:"id"`
+	CompetitorID int    `json:"competitor"`
+	Params       string `json:"params"`
+}
+
+func marshal(record Record) ([]byte, error) {
+	data, err := json.Marshal(payload{
+		Feed:         record.Feed,
+		Time:         record.Event.RawTime,
+		ID:           record.Event.ID,
+		CompetitorID: record.Event.CompetitorID,
+		Params:       record.Event.ExtraParams,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data))) //nolint:gosec // the length is checked by Append
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(data, crcTable()))
+	copy(buf[headerSize:], data)
+
+	return buf, nil
+}
+
+func unmarshal(data []byte) (Record, error) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Record{}, err
+	}
+	if p.Feed < 0 {
+		return Record{}, fmt.Errorf("invalid feed position %d", p.Feed)
+	}
+	if p.Time == "" {
+		return Record{}, errors.New("missing event time")
+	}
+
+	return Record{Feed: p.Feed, Event: utils.Event{
+		ID:           p.ID,
+		RawTime:      p.Time,
+		CompetitorID: p.CompetitorID,
+		ExtraParams:  p.Params,
+	}}, nil
+}
+
+// Append writes a record and syncs it as the policy says. When it returns nil
+// the record will be read back by the next Open, unless the policy is not
+// SyncAlways and the machine fails before the sync.
+func (j *Journal) Append(record Record) error {
+	buf, err := marshal(record)
+	if err != nil {
+		return fmt.Errorf("cannot encode journal record: %w", err)
+	}
+	if len(buf)-headerSize > maxPayloadSize {
+		return fmt.Errorf("journal record of %d bytes is too large", len(buf)-headerSize)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(buf); err != nil {
+		return fmt.Errorf("cannot write journal: %w", err)
+	}
+	j.dirty = true
+
+	switch j.policy {
+	case SyncAlways:
+		return j.sync()
+	case SyncInterval:
+		if time.Since(j.lastSync) >= j.interval {
+			return j.sync()
+		}
+	case SyncNever:
+	}
+
+	return nil
+}
+
+// Close syncs the journal and closes it.
+func (j *Journal) Close() error {
+	select {
+	case <-j.done:
+	default:
+		close(j.done)
+	}
+	<-j.stopped
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	syncErr := j.sync()
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+// sync flushes the file. The lock must be held.
+func (j *Journal) sync() error {
+	if !j.dirty {
+		return nil
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync journal: %w", err)
+	}
+	j.dirty = false
+	j.lastSync = time.Now()
+	return nil
+}
+
+// syncLoop flushes records that were written during a quiet period, which
+// Append only syncs on the next write.
+func (j *Journal) syncLoop() {
+	defer close(j.stopped)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-ticker.C:
+			j.mu.Lock()
+			_ = j.sync()
+			j.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package journal_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/lib/journal"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+var records = []journal.Record{
+	{Feed: 1, Event: utils.Event{RawTime: "[09:30:00.000]", ID: 1, CompetitorID: 1}},
+	{Feed: 2, Event: utils.Event{RawTime: "[09:40:00.000]", ID: 2, CompetitorID: 1, ExtraParams: "10:00:00.000"}},
+	{Feed: 0, Event: utils.Event{RawTime: "[10:00:00.000]", ID: 4, CompetitorID: 1}},
+	{Feed: 0, Event: utils.Event{RawTime: "[10:20:00.000]", ID: 11, CompetitorID: 1, ExtraParams: "Lost"}},
+}
+
+func writeJournal(t *testing.T, path string, policy journal.SyncPolicy, records []journal.Record) {
+	t.Helper()
+
+	j, recovery, err := journal.Open(path, policy, 0)
+	require.NoError(t, err)
+	require.Nil(t, recovery.Tail)
+	for _, record := range records {
+		require.NoError(t, j.Append(record))
+	}
+	require.NoError(t, j.Close())
+}
+
+func reopen(t *testing.T, path string) *journal.Recovery {
+	t.Helper()
+
+	j, recovery, err := journal.Open(path, journal.SyncAlways, 0)
+	require.NoError(t, err)
+	require.NoError(t, j.Close())
+	return recovery
+}
+
+func TestJournalRoundTrip(t *testing.T) {
+	for _, policy := range []journal.SyncPolicy{journal.SyncAlways, journal.SyncInterval, journal.SyncNever} {
+		t.Run(string(policy), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "race.journal")
+
+			writeJournal(t, path, policy, records[:2])
+			writeJournal(t, path, policy, records[2:])
+
+			recovery := reopen(t, path)
+			assert.Nil(t, recovery.Tail)
+			assert.Equal(t, records, recovery.Records)
+		})
+	}
+}
+
+func TestJournalKeepsParamsVerbatim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "race.journal")
+	params := []journal.Record{
+		{Feed: 1, Event: utils.Event{RawTime: "[10:20:00.000]", ID: 11, CompetitorID: 1}},
+		{Feed: 2, Event: utils.Event{RawTime: "[10:21:00.000]", ID: 11, CompetitorID: 2, ExtraParams: "Lost in the forest"}},
+		{Event: utils.Event{RawTime: "[10:22:00.000]", ID: 11, CompetitorID: 3, ExtraParams: "line1\nline2"}},
+		{Event: utils.Event{RawTime: "[10:23:00.000]", ID: 11, CompetitorID: 4, ExtraParams: "  padded  "}},
+	}
+
+	writeJournal(t, path, journal.SyncAlways, params)
+
+	recovery := reopen(t, path)
+	assert.Nil(t, recovery.Tail)
+	assert.Equal(t, params, recovery.Records)
+}
+
+func TestJournalUnreadableRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "race.journal")
+	writeJournal(t, path, journal.SyncAlways, records)
+
+	payload := []byte("not a record")
+	frame := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crc32.MakeTable(crc32.Castagnoli)))
+	frame = append(frame, payload...)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data = append(data, frame...)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	_, _, err = journal.Open(path, journal.SyncAlways, 0)
+	require.ErrorContains(t, err, "cannot read journal record")
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, after, "a record with a valid checksum must not be cut off")
+	assert.NoFileExists(t, path+".corrupt")
+}
+
+func TestJournalIntervalSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "race.journal")
+
+	j, _, err := journal.Open(path, journal.SyncInterval, 5*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, j.Append(records[0]))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, j.Append(records[1]))
+	require.NoError(t, j.Close())
+
+	assert.Equal(t, records[:2], reopen(t, path).Records)
+}
+
+func TestJournalCorruptTail(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(data []byte) []byte
+		reason string
+	}{
+		{
+			name:   "truncated record",
+			damage: func(data []byte) []byte { return data[:len(data)-3] },
+			reason: "truncated record",
+		},
+		{
+			name:   "truncated header",
+			damage: func(data []byte) []byte { return append(data, 0, 0, 0) },
+			reason: "truncated record header",
+		},
+		{
+			name: "checksum mismatch",
+			damage: func(data []byte) []byte {
+				data[len(data)-1] ^= 0xff
+				return data
+			},
+			reason: "checksum mismatch",
+		},
+		{
+			name:   "garbage",
+			damage: func(data []byte) []byte { return append(data, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0) },
+			reason: "invalid record length 4294967295",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "race.journal")
+			writeJournal(t, path, journal.SyncAlways, records)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			good := len(data)
+			require.NoError(t, os.WriteFile(path, tt.damage(data), 0600))
+
+			j, recovery, err := journal.Open(path, journal.SyncAlways, 0)
+			require.NoError(t, err)
+			require.NotNil(t, recovery.Tail)
+			assert.Equal(t, tt.reason, recovery.Tail.Reason)
+			assert.Equal(t, path+".corrupt", recovery.Tail.Saved)
+
+			saved, err := os.ReadFile(recovery.Tail.Saved)
+			require.NoError(t, err)
+			assert.Len(t, saved, int(recovery.Tail.Size))
+
+			want := records
+			if recovery.Tail.Offset < int64(good) {
+				want = records[:len(records)-1]
+			}
+			assert.Equal(t, want, recovery.Records)
+
+			next := journal.Record{Event: utils.Event{RawTime: "[10:30:00.000]", ID: 1, CompetitorID: 2}}
+			require.NoError(t, j.Append(next))
+			require.NoError(t, j.Close())
+
+			recovery = reopen(t, path)
+			assert.Nil(t, recovery.Tail, "new records must follow the last good one")
+			assert.Equal(t, append(want[:len(want):len(want)], next), recovery.Records)
+		})
+	}
+}
+
+func TestJournalHeader(t *testing.T) {
+	dir := t.TempDir()
+
+	other := filepath.Join(dir, "events")
+	require.NoError(t, os.WriteFile(other, []byte("[09:30:00.000] 1 1\n"), 0600))
+	_, _, err := journal.Open(other, journal.SyncAlways, 0)
+	require.ErrorContains(t, err, "is not a journal")
+
+	partial := filepath.Join(dir, "partial.journal")
+	require.NoError(t, os.WriteFile(partial, []byte("BIATH"), 0600))
+	writeJournal(t, partial, journal.SyncAlways, records[:1])
+	assert.Equal(t, records[:1], reopen(t, partial).Records)
+
+	_, _, err = journal.Open(filepath.Join(dir, "race.journal"), "sometimes", 0)
+	require.Error(t, err)
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	policy, err := journal.ParseSyncPolicy("interval")
+	require.NoError(t, err)
+	assert.Equal(t, journal.SyncInterval, policy)
+
+	_, err = journal.ParseSyncPolicy("sometimes")
+	require.Error(t, err)
+}
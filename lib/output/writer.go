@@ -0,0 +1,148 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Mode selects how a Writer treats an existing file.
+type Mode int
+
+const (
+	// ModeReplace writes a temporary file next to the target and renames it
+	// into place on Commit, so readers see either the old or the new file.
+	ModeReplace Mode = iota
+	// ModeAppend appends to the target, creating it if needed.
+	ModeAppend
+)
+
+// Writer writes one output artefact. Nothing is visible under the target path
+// in ModeReplace until Commit succeeds.
+type Writer struct {
+	path string
+	mode Mode
+	file *os.File
+	buf  *bufio.Writer
+	done bool
+}
+
+func Create(path string, mode Mode) (*Writer, error) {
+	var file *os.File
+	var err error
+
+	switch mode {
+	case ModeAppend:
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	case ModeReplace:
+		file, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	default:
+		return nil, fmt.Errorf("unknown output mode %d", mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open output file: %w", err)
+	}
+
+	return &Writer{
+		path: path,
+		mode: mode,
+		file: file,
+		buf:  bufio.NewWriter(file),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Flush writes the buffered data to the file and syncs it. In ModeAppend this
+// makes it visible to readers of the target, which suits logs written live.
+func (w *Writer) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Commit flushes and syncs the data and, in ModeReplace, renames the
+// temporary file over the target.
+func (w *Writer) Commit() error {
+	if w.done {
+		return errors.New("output file already closed")
+	}
+	w.done = true
+
+	err := w.buf.Flush()
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		w.removeTemp()
+		return fmt.Errorf("cannot write output file %s: %w", w.path, err)
+	}
+
+	if w.mode == ModeAppend {
+		return nil
+	}
+
+	if err := os.Rename(w.file.Name(), w.path); err != nil {
+		w.removeTemp()
+		return fmt.Errorf("cannot replace output file %s: %w", w.path, err)
+	}
+
+	syncDir(filepath.Dir(w.path))
+
+	return nil
+}
+
+// Abort discards the data that has not been committed. In ModeReplace the
+// target is left untouched; in ModeAppend only the still buffered data is
+// dropped. It is a no-op after Commit, so it can be deferred.
+func (w *Writer) Abort() {
+	if w.done {
+		return
+	}
+	w.done = true
+
+	_ = w.file.Close()
+	w.removeTemp()
+}
+
+func (w *Writer) removeTemp() {
+	if w.mode == ModeReplace {
+		_ = os.Remove(w.file.Name())
+	}
+}
+
+// WriteFile creates the file at path, fills it with write and commits it.
+// If write fails the target is left as it was.
+func WriteFile(path string, mode Mode, write func(w io.Writer) error) error {
+	w, err := Create(path, mode)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+
+	if err := write(w); err != nil {
+		return fmt.Errorf("cannot write output file %s: %w", path, err)
+	}
+
+	return w.Commit()
+}
+
+// syncDir makes a rename durable. Some platforms cannot sync directories, and
+// the rename has already succeeded, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
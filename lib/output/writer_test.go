@@ -0,0 +1,87 @@
+package output_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/lib/output"
+)
+
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestWriteFileReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "result.txt")
+
+	require.NoError(t, output.WriteFile(path, output.ModeReplace, writeString("first\n")))
+	require.NoError(t, output.WriteFile(path, output.ModeReplace, writeString("second\n")))
+	assert.Equal(t, "second\n", readFile(t, path))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must not be left behind")
+}
+
+func TestWriteFileAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+
+	require.NoError(t, output.WriteFile(path, output.ModeAppend, writeString("first\n")))
+	require.NoError(t, output.WriteFile(path, output.ModeAppend, writeString("second\n")))
+	assert.Equal(t, "first\nsecond\n", readFile(t, path))
+}
+
+func TestWriteFileFailureKeepsTarget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "result.txt")
+	require.NoError(t, output.WriteFile(path, output.ModeReplace, writeString("old\n")))
+
+	errBroken := errors.New("broken")
+	err := output.WriteFile(path, output.ModeReplace, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "half")
+		return errBroken
+	})
+	require.ErrorIs(t, err, errBroken)
+	assert.Equal(t, "old\n", readFile(t, path))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriterNotVisibleBeforeCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.txt")
+
+	w, err := output.Create(path, output.ModeReplace)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "data\n")
+	require.NoError(t, err)
+	assert.NoFileExists(t, path)
+
+	require.NoError(t, w.Commit())
+	assert.Equal(t, "data\n", readFile(t, path))
+	require.Error(t, w.Commit())
+}
+
+func TestCreateMissingDirectory(t *testing.T) {
+	_, err := output.Create(filepath.Join(t.TempDir(), "missing", "result.txt"), output.ModeReplace)
+	require.Error(t, err)
+}
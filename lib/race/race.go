@@ -0,0 +1,408 @@
+package race
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/journal"
+	"biathlon-competitions-prototype/lib/logger/sl"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+const subscriberBuffer = 64
+
+// Update kinds sent to subscribers.
+const (
+	UpdateLog      = "log"
+	UpdateOutgoing = "outgoing"
+	UpdateRanking  = "ranking"
+)
+
+// Update is a change of the race state pushed to subscribers. Data is one of
+// LogLine, OutgoingEvent or Ranking.
+type Update struct {
+	Kind string
+	Data any
+}
+
+type LogLine struct {
+	Index int    `json:"index"`
+	Line  string `json:"line"`
+}
+
+type OutgoingEvent struct {
+	ID           int    `json:"id"`
+	Time         string `json:"time"`
+	CompetitorID int    `json:"competitor"`
+}
+
+// Ranking is the competitor order of the standings.
+type Ranking struct {
+	Competitors []int `json:"competitors"`
+}
+
+// Applied is what a single incoming event produced. Warnings are the
+// validation warnings of the event, it was applied anyway.
+type Applied struct {
+	Lines    []string
+	Outgoing []utils.Event
+	Warnings []utils.Diagnostic
+}
+
+// ErrClosed is returned by Apply once the race is closed.
+var ErrClosed = errors.New("race is closed")
+
+// Race is the live state of a race, shared by the event feeds and the HTTP
+// server. It is safe for concurrent use.
+type Race struct {
+	mu          sync.RWMutex
+	cfg         *configs.Config
+	log         *slog.Logger
+	processor   *utils.Processor
+	validator   *utils.Validator
+	diagnostics int
+	ranking     []int
+	subscribers map[chan Update]struct{}
+	closed      bool
+
+	journal *journal.Journal
+	// feed is the position in the events file of the last event applied from it.
+	feed int
+	// failed is set when an accepted event could not be journaled. The race
+	// state can no longer be recovered, so no more events are taken.
+	failed error
+}
+
+func New(cfg *configs.Config, log *slog.Logger) *Race {
+	// Submitted events race the feed and may arrive after later ones.
+	validator := utils.NewValidator(cfg, true)
+	validator.AcceptLate()
+
+	return &Race{
+		cfg:         cfg,
+		log:         log,
+		processor:   utils.NewProcessor(cfg),
+		validator:   validator,
+		subscribers: make(map[chan Update]struct{}),
+	}
+}
+
+// Restore replays journaled records, so the race continues where a previous
+// run stopped. Records that no longer pass validation, e.g. after a change of
+// the config, are logged and skipped. It returns the number of records
+// applied.
+func (r *Race) Restore(records []journal.Record) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restored := 0
+	for _, record := range records {
+		if _, err := r.check(record.Event); err != nil {
+			r.log.Warn("journaled event rejected", slog.String("event", record.Event.String()), sl.Err(err))
+			continue
+		}
+		r.apply(record.Event)
+		r.feed = max(r.feed, record.Feed)
+		restored++
+	}
+	return restored
+}
+
+// UseJournal makes Apply and ApplyFeed write every accepted event to j before
+// it is applied.
+func (r *Race) UseJournal(j *journal.Journal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.journal = j
+}
+
+// Format is the race format of the config.
+func (r *Race) Format() configs.Format {
+	return r.cfg.Format
+}
+
+// FeedPosition is the position in the events file of the last event applied
+// from it, including restored ones. A restarted feed skips the events up to it.
+func (r *Race) FeedPosition() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.feed
+}
+
+// Apply validates an event submitted over the API and processes it. An event
+// that breaks a validation rule is rejected with a *utils.ValidationError and
+// leaves the race unchanged. Otherwise the subscribers are notified of the
+// output log lines, the outgoing events and a changed ranking.
+func (r *Race) Apply(event utils.Event) (Applied, error) {
+	return r.submit(journal.Record{Event: event})
+}
+
+// ApplyFeed is Apply for the event at the 1-based position of the events file.
+func (r *Race) ApplyFeed(position int, event utils.Event) (Applied, error) {
+	return r.submit(journal.Record{Feed: position, Event: event})
+}
+
+func (r *Race) submit(record journal.Record) (Applied, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return Applied{}, ErrClosed
+	}
+	if r.failed != nil {
+		return Applied{}, r.failed
+	}
+
+	warnings, err := r.check(record.Event)
+	if err != nil {
+		return Applied{}, err
+	}
+
+	if r.journal != nil {
+		if err := r.journal.Append(record); err != nil {
+			r.failed = err
+			r.log.Error("cannot journal an accepted event, no more events are taken", sl.Err(err))
+			return Applied{}, err
+		}
+	}
+
+	for _, diagnostic := range warnings {
+		r.log.Warn("invalid event", slog.String("diagnostic", diagnostic.String()))
+	}
+
+	applied := r.apply(record.Event)
+	applied.Warnings = warnings
+	r.feed = max(r.feed, record.Feed)
+	return applied, nil
+}
+
+// check validates the event and returns its warnings. The lock must be held.
+func (r *Race) check(event utils.Event) ([]utils.Diagnostic, error) {
+	err := r.validator.Check(event)
+	diagnostics := r.validator.Diagnostics()
+	found := slices.Clone(diagnostics[r.diagnostics:])
+	r.diagnostics = len(diagnostics)
+	return found, err
+}
+
+// Finish marks the end of the events feed: competitors whose start window
+// expired without a start are disqualified and the subscribers notified.
+func (r *Race) Finish() (Applied, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return Applied{}, ErrClosed
+	}
+
+	return r.process(r.processor.Close), nil
+}
+
+// apply runs the event through the processor. The lock must be held.
+func (r *Race) apply(event utils.Event) Applied {
+	return r.process(func() []string { return r.processor.Apply(event) })
+}
+
+// process runs a step of the processor and publishes what it produced. The
+// lock must be held.
+func (r *Race) process(step func() []string) Applied {
+	before := len(r.processor.Events())
+	lineCount := len(r.processor.Output())
+
+	applied := Applied{Lines: step()}
+	for _, e := range r.processor.Events()[before:] {
+		if e.IsOutgoing() {
+			applied.Outgoing = append(applied.Outgoing, e)
+		}
+	}
+
+	for i, line := range applied.Lines {
+		r.publish(Update{Kind: UpdateLog, Data: LogLine{Index: lineCount + i, Line: line}})
+	}
+	for _, e := range applied.Outgoing {
+		r.publish(Update{Kind: UpdateOutgoing, Data: OutgoingEvent{
+			ID:           e.ID,
+			Time:         strings.Trim(e.RawTime, "[]"),
+			CompetitorID: e.CompetitorID,
+		}})
+	}
+
+	ranking := r.currentRanking()
+	if !slices.Equal(ranking, r.ranking) {
+		r.ranking = ranking
+		r.publish(Update{Kind: UpdateRanking, Data: Ranking{Competitors: slices.Clone(ranking)}})
+	}
+
+	return applied
+}
+
+// Report writes the current standings with the reporter.
+func (r *Race) Report(reporter report.Reporter) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := reporter.Report(&buf, report.NewStandings(r.cfg, r.processor)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CompetitorDetail is the result of a competitor together with where the
+// competitor is on the course.
+type CompetitorDetail struct {
+	report.JSONCompetitor
+
+	Registered    bool   `json:"registered"`
+	PlannedStart  string `json:"plannedStart,omitempty"`
+	Started       bool   `json:"started"`
+	CurrentLap    int    `json:"currentLap"`
+	OnFiringRange bool   `json:"onFiringRange"`
+	OnPenaltyLoop bool   `json:"onPenaltyLoop"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+func (r *Race) Competitor(id int) (CompetitorDetail, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	competitor, ok := r.processor.Competitor(id)
+	if !ok {
+		return CompetitorDetail{}, false
+	}
+	result, ok := r.processor.Result(id)
+	if !ok {
+		return CompetitorDetail{}, false
+	}
+
+	detail := CompetitorDetail{
+		JSONCompetitor: report.NewJSONCompetitor(result),
+		Registered:     competitor.Registered,
+		Started:        competitor.HasStarted,
+		CurrentLap:     competitor.CurrentLap + 1,
+		OnFiringRange:  competitor.OnFiringRange,
+		OnPenaltyLoop:  competitor.OnPenaltyLoop,
+		Comment:        competitor.Comment,
+	}
+	if !competitor.PlannedStart.IsZero() {
+		detail.PlannedStart = competitor.PlannedStart.Format("15:04:05.000")
+	}
+	if competitor.IsFinishedCompletely {
+		detail.CurrentLap = competitor.CurrentLap
+	}
+
+	return detail, true
+}
+
+// Log returns the output log lines from index since on.
+func (r *Race) Log(since int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lines := r.processor.Output()
+	if since < 0 || since >= len(lines) {
+		return []string{}
+	}
+	return slices.Clone(lines[since:])
+}
+
+// Ranking returns the current competitor order.
+func (r *Race) Ranking() Ranking {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return Ranking{Competitors: r.currentRanking()}
+}
+
+// Subscribe returns a channel of updates and a function to stop receiving
+// them. A subscriber that falls behind is dropped and its channel closed. The
+// channel is also closed when the race is closed.
+func (r *Race) Subscribe() (<-chan Update, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	updates := make(chan Update, subscriberBuffer)
+	if r.closed {
+		close(updates)
+		return updates, func() {}
+	}
+	r.subscribers[updates] = struct{}{}
+
+	return updates, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.unsubscribe(updates)
+	}
+}
+
+// Close ends every subscription and stops taking events.
+func (r *Race) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for updates := range r.subscribers {
+		r.unsubscribe(updates)
+	}
+}
+
+func (r *Race) unsubscribe(updates chan Update) {
+	if _, ok := r.subscribers[updates]; ok {
+		delete(r.subscribers, updates)
+		close(updates)
+	}
+}
+
+func (r *Race) publish(update Update) {
+	for updates := range r.subscribers {
+		select {
+		case updates <- update:
+		default:
+			r.log.Warn("dropping a slow subscriber")
+			r.unsubscribe(updates)
+		}
+	}
+}
+
+// currentRanking orders the competitors by their progress on the course: the
+// most laps completed first and, on the same lap, the earliest time. Those who
+// did not start or did not finish come last.
+func (r *Race) currentRanking() []int {
+	results := make([]*utils.Result, 0, len(r.processor.Results()))
+	for _, result := range r.processor.Results() {
+		results = append(results, result)
+	}
+
+	slices.SortFunc(results, func(a, b *utils.Result) int {
+		return cmp.Or(
+			cmp.Compare(outOfRace(a), outOfRace(b)),
+			cmp.Compare(len(b.LapDurations), len(a.LapDurations)),
+			cmp.Compare(a.TotalTime, b.TotalTime),
+			cmp.Compare(a.CompetitorID, b.CompetitorID),
+		)
+	})
+
+	ranking := make([]int, len(results))
+	for i, result := range results {
+		ranking[i] = result.CompetitorID
+	}
+	return ranking
+}
+
+func outOfRace(result *utils.Result) int {
+	switch result.Status {
+	case utils.StatusNotStarted, utils.StatusNotFinished, utils.StatusDisqualified:
+		return 1
+	default:
+		return 0
+	}
+}
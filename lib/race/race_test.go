@@ -0,0 +1,337 @@
+package race_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/journal"
+	"biathlon-competitions-prototype/lib/logger/slogdiscard"
+	"biathlon-competitions-prototype/lib/race"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func newRace() *race.Race {
+	cfg := &configs.Config{
+		Format:        configs.FormatSprint,
+		Laps:          1,
+		LapLength:     3000,
+		PenaltyLength: 150,
+		FiringLines:   1,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+	}
+	return race.New(cfg, slogdiscard.NewDiscardLogger())
+}
+
+func apply(t *testing.T, r *race.Race, events ...utils.Event) {
+	t.Helper()
+
+	for _, event := range events {
+		_, err := r.Apply(event)
+		require.NoError(t, err)
+	}
+}
+
+func receive(t *testing.T, updates <-chan race.Update) race.Update {
+	t.Helper()
+
+	select {
+	case update, open := <-updates:
+		require.True(t, open, "the updates channel was closed")
+		return update
+	default:
+		t.Fatal("no update")
+		return race.Update{}
+	}
+}
+
+func TestApplyReturnsOutgoingEvents(t *testing.T) {
+	r := newRace()
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+	)
+
+	applied, err := r.Apply(utils.Event{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10})
+	require.NoError(t, err)
+	assert.NotEmpty(t, applied.Lines)
+	require.Len(t, applied.Outgoing, 1)
+	assert.Equal(t, utils.EventFinished, applied.Outgoing[0].ID)
+	assert.Equal(t, 1, applied.Outgoing[0].CompetitorID)
+
+	assert.Len(t, r.Log(0), 5)
+	assert.Len(t, r.Log(4), 1)
+	assert.Empty(t, r.Log(10))
+}
+
+func TestSubscribersReceiveUpdates(t *testing.T) {
+	r := newRace()
+	updates, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	apply(t, r, utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1})
+	update := receive(t, updates)
+	assert.Equal(t, race.UpdateLog, update.Kind)
+	assert.Equal(t, race.LogLine{Index: 0, Line: "[09:30:00.000] The competitor(1) registered"}, update.Data)
+
+	update = receive(t, updates)
+	assert.Equal(t, race.UpdateRanking, update.Kind)
+	assert.Equal(t, race.Ranking{Competitors: []int{1}}, update.Data)
+
+	apply(t, r,
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		utils.Event{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10},
+	)
+
+	var kinds []string
+	var outgoing []race.OutgoingEvent
+	for len(updates) > 0 {
+		update := receive(t, updates)
+		kinds = append(kinds, update.Kind)
+		if event, ok := update.Data.(race.OutgoingEvent); ok {
+			outgoing = append(outgoing, event)
+		}
+	}
+	assert.NotContains(t, kinds, race.UpdateRanking, "the ranking of a single competitor never changes")
+	assert.Equal(t, []race.OutgoingEvent{{ID: utils.EventFinished, Time: "10:10:00.000", CompetitorID: 1}}, outgoing)
+}
+
+func TestRankingFollowsProgress(t *testing.T) {
+	cfg := &configs.Config{
+		Format:        configs.FormatSprint,
+		Laps:          2,
+		LapLength:     3000,
+		PenaltyLength: 150,
+		FiringLines:   1,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+	}
+	r := race.New(cfg, slogdiscard.NewDiscardLogger())
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 2, ID: 1},
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 3, ID: 1},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:00:30.000"},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 3, ID: 2, ExtraParams: "10:01:00.000"},
+		utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		utils.Event{RawTime: "[10:00:30.000]", CompetitorID: 2, ID: 4},
+		utils.Event{RawTime: "[10:01:00.000]", CompetitorID: 3, ID: 4},
+		utils.Event{RawTime: "[10:09:30.000]", CompetitorID: 2, ID: 10},
+	)
+	assert.Equal(t, []int{2, 1, 3}, r.Ranking().Competitors, "a completed lap puts an athlete ahead")
+
+	apply(t, r, utils.Event{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10})
+	assert.Equal(t, []int{2, 1, 3}, r.Ranking().Competitors, "the faster lap stays ahead")
+
+	apply(t, r,
+		utils.Event{RawTime: "[10:10:30.000]", CompetitorID: 3, ID: 11, ExtraParams: "Lost"},
+		utils.Event{RawTime: "[10:20:00.000]", CompetitorID: 1, ID: 10},
+	)
+	assert.Equal(t, []int{1, 2, 3}, r.Ranking().Competitors)
+}
+
+func TestRankingChanges(t *testing.T) {
+	r := newRace()
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 2, ID: 1},
+	)
+	before := r.Ranking()
+
+	apply(t, r,
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:00:30.000"},
+		utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		utils.Event{RawTime: "[10:00:30.000]", CompetitorID: 2, ID: 4},
+	)
+
+	updates, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	apply(t, r,
+		utils.Event{RawTime: "[10:09:00.000]", CompetitorID: 2, ID: 10},
+		utils.Event{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10},
+	)
+
+	var rankings []race.Ranking
+	for len(updates) > 0 {
+		if ranking, ok := receive(t, updates).Data.(race.Ranking); ok {
+			rankings = append(rankings, ranking)
+		}
+	}
+	require.NotEmpty(t, rankings)
+	assert.Equal(t, r.Ranking(), rankings[len(rankings)-1])
+	assert.NotEqual(t, before, r.Ranking())
+}
+
+func TestCompetitorDetail(t *testing.T) {
+	r := newRace()
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		utils.Event{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+	)
+
+	detail, ok := r.Competitor(1)
+	require.True(t, ok)
+	assert.Equal(t, 1, detail.ID)
+	assert.True(t, detail.Registered)
+	assert.True(t, detail.Started)
+	assert.True(t, detail.OnFiringRange)
+	assert.Equal(t, "10:00:00.000", detail.PlannedStart)
+	assert.Equal(t, 1, detail.CurrentLap)
+
+	_, ok = r.Competitor(2)
+	assert.False(t, ok)
+}
+
+func TestReport(t *testing.T) {
+	r := newRace()
+	apply(t, r, utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1})
+
+	body, err := r.Report(report.JSONReporter{})
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"id":1`)
+}
+
+func TestFinishDisqualifiesNoShows(t *testing.T) {
+	r := newRace()
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+	)
+
+	updates, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	applied, err := r.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[10:00:30.000] The competitor(1) is disqualified"}, applied.Lines)
+	require.Len(t, applied.Outgoing, 1)
+	assert.Equal(t, utils.EventDisqualified, applied.Outgoing[0].ID)
+
+	assert.Equal(t, race.UpdateLog, receive(t, updates).Kind)
+	assert.Equal(t, race.UpdateOutgoing, receive(t, updates).Kind)
+
+	detail, ok := r.Competitor(1)
+	require.True(t, ok)
+	assert.Equal(t, "not-started", detail.Status)
+
+	r.Close()
+	_, err = r.Finish()
+	require.ErrorIs(t, err, race.ErrClosed)
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	r := newRace()
+	updates, unsubscribe := r.Subscribe()
+
+	r.Close()
+	_, open := <-updates
+	assert.False(t, open)
+	unsubscribe()
+
+	late, _ := r.Subscribe()
+	_, open = <-late
+	assert.False(t, open)
+}
+
+func TestApplyRejectsInvalidEvents(t *testing.T) {
+	r := newRace()
+	apply(t, r, utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1})
+
+	_, err := r.Apply(utils.Event{RawTime: "[09:35:00.000]", CompetitorID: 1, ID: 4})
+	var validationErr *utils.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, utils.RuleNoDraw, validationErr.Diagnostic.Rule)
+	assert.Len(t, r.Log(0), 1, "a rejected event must not change the race")
+
+	applied, err := r.Apply(utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 1})
+	require.NoError(t, err)
+	require.Len(t, applied.Warnings, 1)
+	assert.Equal(t, utils.RuleDuplicateRegistered, applied.Warnings[0].Rule)
+}
+
+func TestApplyAcceptsLateEvents(t *testing.T) {
+	r := newRace()
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		utils.Event{RawTime: "[10:05:00.100]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+	)
+
+	applied, err := r.Apply(utils.Event{RawTime: "[10:05:00.000]", CompetitorID: 2, ID: 1})
+	require.NoError(t, err, "an event submitted 100ms late is taken")
+	require.Len(t, applied.Warnings, 1)
+	assert.Equal(t, utils.RuleOutOfOrder, applied.Warnings[0].Rule)
+	assert.Equal(t, utils.SeverityWarning, applied.Warnings[0].Severity)
+	assert.Equal(t, []string{"[10:05:00.000] The competitor(2) registered"}, applied.Lines)
+}
+
+func TestJournalAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "race.journal")
+
+	j, recovery, err := journal.Open(path, journal.SyncAlways, 0)
+	require.NoError(t, err)
+	require.Empty(t, recovery.Records)
+
+	r := newRace()
+	r.UseJournal(j)
+	_, err = r.ApplyFeed(1, utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1})
+	require.NoError(t, err)
+	_, err = r.ApplyFeed(3, utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"})
+	require.NoError(t, err)
+	_, err = r.Apply(utils.Event{RawTime: "[09:45:00.000]", CompetitorID: 2, ID: 4})
+	require.Error(t, err, "not registered")
+	apply(t, r, utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4})
+	assert.Equal(t, 3, r.FeedPosition())
+
+	r.Close()
+	_, err = r.Apply(utils.Event{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"})
+	require.ErrorIs(t, err, race.ErrClosed)
+	require.NoError(t, j.Close())
+
+	j, recovery, err = journal.Open(path, journal.SyncAlways, 0)
+	require.NoError(t, err)
+	defer j.Close()
+	require.Len(t, recovery.Records, 3, "only accepted events are journaled")
+
+	restored := newRace()
+	assert.Equal(t, 3, restored.Restore(recovery.Records))
+	assert.Equal(t, 3, restored.FeedPosition())
+	assert.Equal(t, r.Log(0), restored.Log(0))
+
+	restored.UseJournal(j)
+	apply(t, restored, utils.Event{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"})
+	detail, ok := restored.Competitor(1)
+	require.True(t, ok)
+	assert.True(t, detail.OnFiringRange)
+}
+
+func TestJournalFailureStopsTheRace(t *testing.T) {
+	j, _, err := journal.Open(filepath.Join(t.TempDir(), "race.journal"), journal.SyncAlways, 0)
+	require.NoError(t, err)
+	require.NoError(t, j.Close())
+
+	r := newRace()
+	r.UseJournal(j)
+
+	event := utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1}
+	_, err = r.Apply(event)
+	require.Error(t, err)
+	assert.Empty(t, r.Log(0), "an event that was not journaled must not be applied")
+
+	_, err = r.Apply(event)
+	require.Error(t, err)
+}
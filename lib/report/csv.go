@@ -0,0 +1,139 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// CSVReporter writes one row per competitor with the time, split and speed of
+// every lap, and the total time and average speed of the penalty laps.
+// Relay teams follow in a second table, separated by an empty line. Durations
+// are HH:MM:SS.sss and speeds m/s, so spreadsheets read them as they are.
+type CSVReporter struct{}
+
+func (CSVReporter) Extension() string {
+	return "csv"
+}
+
+func (CSVReporter) Report(w io.Writer, standings *Standings) error {
+	out := csv.NewWriter(w)
+
+	laps := 0
+	for _, result := range standings.Results {
+		laps = max(laps, result.Laps)
+	}
+
+	header := []string{"position", "id", "status", "finish_time", "total_time"}
+	for i := range laps {
+		lap := strconv.Itoa(i + 1)
+		header = append(header, "lap_"+lap+"_time", "lap_"+lap+"_split", "lap_"+lap+"_speed")
+	}
+	header = append(header, "penalty_time", "penalty_speed", "hits", "shots", "skipped_loops")
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for i, result := range standings.Results {
+		if err := out.Write(competitorRow(i+1, result, laps)); err != nil {
+			return err
+		}
+	}
+
+	if len(standings.Teams) > 0 {
+		if err := writeTeams(out, standings.Teams); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+func competitorRow(position int, result *utils.Result, laps int) []string {
+	row := []string{
+		strconv.Itoa(position),
+		strconv.Itoa(result.CompetitorID),
+		status(result.Status, result.Finished()),
+		"",
+		utils.FormatDurationToTime(result.TotalTime),
+	}
+	if result.Finished() {
+		row[3] = result.FinishTime.Format("15:04:05.000")
+	}
+
+	for i := range laps {
+		if i >= len(result.LapDurations) {
+			row = append(row, "", "", "")
+			continue
+		}
+		row = append(row,
+			utils.FormatDurationToTime(result.LapDurations[i]),
+			result.Splits[i],
+			formatSpeed(result.LapLengths[i], result.LapDurations[i]),
+		)
+	}
+
+	return append(row,
+		result.PenaltyTotalTime,
+		result.PenaltyAvgSpeed,
+		strconv.Itoa(result.Hits),
+		strconv.Itoa(result.Shots),
+		strconv.Itoa(result.SkippedLoops),
+	)
+}
+
+func writeTeams(out *csv.Writer, teams []*utils.TeamResult) error {
+	legs := 0
+	for _, team := range teams {
+		legs = max(legs, len(team.Legs))
+	}
+
+	header := []string{"position", "team", "status", "total_time"}
+	for i := range legs {
+		leg := strconv.Itoa(i + 1)
+		header = append(header, "leg_"+leg+"_athlete", "leg_"+leg+"_time")
+	}
+	header = append(header, "penalty_loops", "spare_rounds")
+
+	if err := out.Write(nil); err != nil {
+		return err
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for position, team := range teams {
+		row := []string{
+			strconv.Itoa(position + 1),
+			strconv.Itoa(team.TeamID),
+			status(team.Status, team.Finished()),
+			utils.FormatDurationToTime(team.TotalTime),
+		}
+		for i := range legs {
+			if i >= len(team.Legs) {
+				row = append(row, "", "")
+				continue
+			}
+			row = append(row, strconv.Itoa(team.Legs[i]), team.LegTimes[i])
+		}
+		row = append(row, strconv.Itoa(team.PenaltyLoops), strconv.Itoa(team.SpareRounds))
+
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatSpeed(distance int, d time.Duration) string {
+	v, ok := speed(distance, d)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
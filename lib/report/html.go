@@ -0,0 +1,244 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+//go:embed results.html
+var defaultHTMLTemplate string
+
+// HTMLReporter writes a self-contained results page. Template is executed
+// with an *HTMLPage; nil uses the built-in page.
+type HTMLReporter struct {
+	Template *template.Template
+}
+
+// HTMLPage is the data the results page template is executed with.
+type HTMLPage struct {
+	Title       string
+	Format      configs.Format
+	Laps        []int
+	Competitors []HTMLCompetitor
+	Teams       []HTMLTeam
+}
+
+// HTMLCompetitor is one row of the results table. The Sort fields hold plain
+// numbers for the sortable columns, -1 where there is no value.
+type HTMLCompetitor struct {
+	Rank         int
+	Bib          int
+	Status       string
+	StatusText   string
+	TotalTime    string
+	TotalSort    int64
+	Laps         []HTMLLap
+	PenaltyLoops int
+	SkippedLoops int
+	PenaltyTime  string
+	PenaltySpeed string
+	Shooting     string
+	Visits       []HTMLVisit
+}
+
+type HTMLLap struct {
+	Time  string
+	Split string
+	Speed string
+	Sort  int64
+}
+
+// HTMLVisit is one stay on the firing range. Loops is the number of penalty
+// loops owed for the misses and Skipped the number of them not skied, as in
+// the JSON report.
+type HTMLVisit struct {
+	Range    int
+	Position string
+	Hits     int
+	Shots    int
+	Loops    int
+	Skipped  int
+}
+
+type HTMLTeam struct {
+	Rank         int
+	ID           int
+	Status       string
+	StatusText   string
+	TotalTime    string
+	TotalSort    int64
+	Legs         []HTMLLeg
+	PenaltyLoops int
+	SpareRounds  int
+}
+
+type HTMLLeg struct {
+	Athlete int
+	Time    string
+}
+
+// ParseHTMLTemplate reads a results page template from a file, for venues that
+// want their own layout.
+func ParseHTMLTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse HTML template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func (HTMLReporter) Extension() string {
+	return "html"
+}
+
+func (r HTMLReporter) Report(w io.Writer, standings *Standings) error {
+	tmpl := r.Template
+	if tmpl == nil {
+		var err error
+		tmpl, err = template.New("results").Parse(defaultHTMLTemplate)
+		if err != nil {
+			return fmt.Errorf("cannot parse HTML template: %w", err)
+		}
+	}
+
+	return tmpl.Execute(w, NewHTMLPage(standings))
+}
+
+// NewHTMLPage prepares the standings for the page. Finished competitors come
+// first in ranking order and are numbered, the others follow unranked.
+func NewHTMLPage(standings *Standings) *HTMLPage {
+	page := &HTMLPage{
+		Title:  formatTitle(standings.Format),
+		Format: standings.Format,
+	}
+
+	laps := 0
+	var finished, others []HTMLCompetitor
+	for _, result := range standings.Results {
+		laps = max(laps, result.Laps)
+		if result.Finished() {
+			competitor := newHTMLCompetitor(result)
+			competitor.Rank = len(finished) + 1
+			finished = append(finished, competitor)
+		} else {
+			others = append(others, newHTMLCompetitor(result))
+		}
+	}
+	page.Competitors = append(finished, others...)
+
+	for i := range laps {
+		page.Laps = append(page.Laps, i+1)
+	}
+	for i := range page.Competitors {
+		for len(page.Competitors[i].Laps) < laps {
+			page.Competitors[i].Laps = append(page.Competitors[i].Laps, HTMLLap{Sort: -1})
+		}
+	}
+
+	rank := 0
+	for _, team := range standings.Teams {
+		row := newHTMLTeam(team)
+		if team.Finished() {
+			rank++
+			row.Rank = rank
+		}
+		page.Teams = append(page.Teams, row)
+	}
+
+	return page
+}
+
+func newHTMLCompetitor(result *utils.Result) HTMLCompetitor {
+	competitor := HTMLCompetitor{
+		Bib:          result.CompetitorID,
+		Status:       status(result.Status, result.Finished()),
+		TotalSort:    -1,
+		SkippedLoops: result.SkippedLoops,
+		Shooting:     result.ShootingStats,
+	}
+	competitor.StatusText = statusText(competitor.Status, result.FinishTime)
+	if result.Finished() {
+		competitor.TotalTime = utils.FormatDurationToTime(result.TotalTime)
+		competitor.TotalSort = result.TotalTime.Milliseconds()
+	}
+
+	for i, d := range result.LapDurations {
+		competitor.Laps = append(competitor.Laps, HTMLLap{
+			Time:  utils.FormatDurationToTime(d),
+			Split: result.Splits[i],
+			Speed: formatSpeed(result.LapLengths[i], d),
+			Sort:  d.Milliseconds(),
+		})
+	}
+
+	competitor.PenaltyTime = result.PenaltyTotalTime
+	competitor.PenaltySpeed = result.PenaltyAvgSpeed
+
+	for _, visit := range result.Visits {
+		competitor.PenaltyLoops += visit.RequiredLoops()
+		competitor.Visits = append(competitor.Visits, HTMLVisit{
+			Range:    visit.Range,
+			Position: string(visit.Position),
+			Hits:     visit.Hits(),
+			Shots:    visit.Shots(),
+			Loops:    visit.RequiredLoops(),
+			Skipped:  visit.SkippedLoops(),
+		})
+	}
+
+	return competitor
+}
+
+func newHTMLTeam(team *utils.TeamResult) HTMLTeam {
+	row := HTMLTeam{
+		ID:           team.TeamID,
+		Status:       status(team.Status, team.Finished()),
+		TotalSort:    -1,
+		PenaltyLoops: team.PenaltyLoops,
+		SpareRounds:  team.SpareRounds,
+	}
+	row.StatusText = statusText(row.Status, time.Time{})
+	if team.Finished() {
+		row.StatusText = team.Status
+		row.TotalTime = utils.FormatDurationToTime(team.TotalTime)
+		row.TotalSort = team.TotalTime.Milliseconds()
+	}
+
+	for i, athlete := range team.Legs {
+		row.Legs = append(row.Legs, HTMLLeg{Athlete: athlete, Time: team.LegTimes[i]})
+	}
+
+	return row
+}
+
+func statusText(status string, finish time.Time) string {
+	switch status {
+	case "finished":
+		return finish.Format("15:04:05.000")
+	case "not-started":
+		return "DNS"
+	case "not-finished":
+		return "DNF"
+	case "disqualified":
+		return "DSQ"
+	default:
+		return "Running"
+	}
+}
+
+func formatTitle(format configs.Format) string {
+	words := strings.Split(string(format), "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ") + " results"
+}
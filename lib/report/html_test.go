@@ -0,0 +1,125 @@
+package report_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func TestNewHTMLPage(t *testing.T) {
+	page := report.NewHTMLPage(sprintStandings(t))
+
+	assert.Equal(t, "Sprint results", page.Title)
+	assert.Equal(t, []int{1, 2}, page.Laps)
+	require.Len(t, page.Competitors, 3)
+
+	winner := page.Competitors[0]
+	assert.Equal(t, 1, winner.Rank)
+	assert.Equal(t, 1, winner.Bib)
+	assert.Equal(t, "10:20:00.000", winner.StatusText)
+	assert.Equal(t, "00:20:00.000", winner.TotalTime)
+	assert.Equal(t, "00:20:00.000", winner.Laps[1].Split)
+	assert.Equal(t, "5.000", winner.Laps[1].Speed)
+	assert.Equal(t, 1, winner.PenaltyLoops)
+	assert.Equal(t, "00:00:30.000", winner.PenaltyTime)
+	assert.Equal(t, "5.000", winner.PenaltySpeed)
+	assert.Equal(t, []report.HTMLVisit{{Range: 1, Position: "prone", Hits: 4, Shots: 5, Loops: 1}}, winner.Visits)
+
+	for _, competitor := range page.Competitors[1:] {
+		assert.Zero(t, competitor.Rank)
+		assert.Equal(t, int64(-1), competitor.TotalSort)
+		assert.Len(t, competitor.Laps, 2)
+	}
+	assert.Equal(t, "DNF", page.Competitors[1].StatusText)
+	assert.Equal(t, "Running", page.Competitors[2].StatusText)
+}
+
+func TestNewHTMLPageIndividual(t *testing.T) {
+	cfg := &configs.Config{
+		Format:      configs.FormatIndividual,
+		Laps:        1,
+		LapLength:   4000,
+		FiringLines: 1,
+		Start:       configs.Clock(10 * time.Hour),
+		StartDelta:  configs.Duration(30 * time.Second),
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range []utils.Event{
+		{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:05:01.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:05:02.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:05:10.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:12:00.000]", CompetitorID: 1, ID: 10},
+	} {
+		processor.Apply(event)
+	}
+
+	page := report.NewHTMLPage(report.NewStandings(cfg, processor))
+
+	require.Len(t, page.Competitors, 1)
+	assert.Zero(t, page.Competitors[0].PenaltyLoops, "misses cost penalty time, not loops")
+	assert.Equal(t, []report.HTMLVisit{{Range: 1, Position: "prone", Hits: 2, Shots: 5}}, page.Competitors[0].Visits)
+}
+
+func TestNewHTMLPageSkippedLoops(t *testing.T) {
+	page := report.NewHTMLPage(skippedLoopStandings(t))
+
+	require.Len(t, page.Competitors, 1)
+	competitor := page.Competitors[0]
+	assert.Equal(t, 3, competitor.PenaltyLoops, "the loops owed, not the loops skied")
+	assert.Equal(t, 2, competitor.SkippedLoops)
+	assert.Equal(t, []report.HTMLVisit{
+		{Range: 1, Position: "prone", Hits: 2, Shots: 5, Loops: 3, Skipped: 2},
+	}, competitor.Visits)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.HTMLReporter{}.Report(&buf, skippedLoopStandings(t)))
+	assert.Contains(t, buf.String(), "3 (2 skipped)")
+}
+
+func TestHTMLReporter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, report.HTMLReporter{}.Report(&buf, sprintStandings(t)))
+
+	page := buf.String()
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>"))
+	assert.Contains(t, page, "<title>Sprint results</title>")
+	assert.Contains(t, page, `<td class="num" data-sort="1200000">00:20:00.000</td>`)
+	assert.Contains(t, page, "prone 4/5")
+	assert.Contains(t, page, "<small>00:00:30.000 &middot; 5.000 m/s</small>")
+	assert.Contains(t, page, "<script>")
+	assert.NotContains(t, page, "src=", "the page must not load external assets")
+	assert.NotContains(t, page, "href=")
+}
+
+func TestHTMLReporterCustomTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.html")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{{range .Competitors}}{{.Bib}}:{{.StatusText}};{{end}}<b>{{.Title}}</b>`,
+	), 0600))
+
+	tmpl, err := report.ParseHTMLTemplate(path)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.HTMLReporter{Template: tmpl}.Report(&buf, sprintStandings(t)))
+	assert.Equal(t, "1:10:20:00.000;2:DNF;3:Running;<b>Sprint results</b>", buf.String())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{{range}}`), 0600))
+	_, err = report.ParseHTMLTemplate(path)
+	require.Error(t, err)
+}
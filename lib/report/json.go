@@ -0,0 +1,181 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// JSONReporter writes the standings as a single JSON document. Durations are
+// integer milliseconds, speeds are m/s and times of day are HH:MM:SS.sss.
+// Splits are the times from the start to the end of every lap.
+type JSONReporter struct {
+	// Indent is used for every nesting level, empty for compact output.
+	Indent string
+}
+
+type jsonStandings struct {
+	Format      configs.Format   `json:"format"`
+	Competitors []JSONCompetitor `json:"competitors"`
+	Teams       []jsonTeam       `json:"teams,omitempty"`
+}
+
+// JSONCompetitor is a competitor in the JSON report.
+type JSONCompetitor struct {
+	ID            int       `json:"id"`
+	Status        string    `json:"status"`
+	FinishTime    string    `json:"finishTime,omitempty"`
+	TotalTimeMs   int64     `json:"totalTimeMs"`
+	Laps          int       `json:"laps"`
+	LapResults    []JSONLap `json:"lapResults"`
+	SplitsMs      []int64   `json:"splitsMs"`
+	PenaltyLaps   []JSONLap `json:"penaltyLaps"`
+	PenaltyTimeMs int64     `json:"penaltyTimeMs"`
+	// PenaltySpeed is the average over every penalty lap, null without one.
+	PenaltySpeed *float64    `json:"penaltySpeed"`
+	Hits         int         `json:"hits"`
+	Shots        int         `json:"shots"`
+	Visits       []JSONVisit `json:"visits"`
+	SkippedLoops int         `json:"skippedLoops"`
+}
+
+type JSONLap struct {
+	TimeMs   int64 `json:"timeMs"`
+	Distance int   `json:"distance"`
+	// Speed is null when the time is not positive.
+	Speed *float64 `json:"speed"`
+}
+
+// JSONVisit is one stay on the firing range. PenaltyLoops is the number of
+// loops owed for the misses, zero in the individual race, and SkippedLoops the
+// number of them not skied.
+type JSONVisit struct {
+	Range        int    `json:"range"`
+	Lap          int    `json:"lap"`
+	Position     string `json:"position"`
+	Hits         int    `json:"hits"`
+	Shots        int    `json:"shots"`
+	SpareRounds  int    `json:"spareRounds"`
+	PenaltyLoops int    `json:"penaltyLoops"`
+	SkippedLoops int    `json:"skippedLoops"`
+}
+
+type jsonTeam struct {
+	ID           int       `json:"id"`
+	Status       string    `json:"status"`
+	TotalTimeMs  int64     `json:"totalTimeMs"`
+	Legs         []jsonLeg `json:"legs"`
+	PenaltyLoops int       `json:"penaltyLoops"`
+	SpareRounds  int       `json:"spareRounds"`
+}
+
+type jsonLeg struct {
+	Athlete int `json:"athlete"`
+	// TimeMs is null for a leg that has not been completed.
+	TimeMs *int64 `json:"timeMs"`
+}
+
+func (JSONReporter) Extension() string {
+	return "json"
+}
+
+func (r JSONReporter) Report(w io.Writer, standings *Standings) error {
+	out := jsonStandings{
+		Format:      standings.Format,
+		Competitors: make([]JSONCompetitor, 0, len(standings.Results)),
+	}
+	for _, result := range standings.Results {
+		out.Competitors = append(out.Competitors, NewJSONCompetitor(result))
+	}
+	for _, team := range standings.Teams {
+		out.Teams = append(out.Teams, newJSONTeam(team))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", r.Indent)
+	return encoder.Encode(out)
+}
+
+// NewJSONCompetitor converts a result to its JSON form.
+func NewJSONCompetitor(result *utils.Result) JSONCompetitor {
+	competitor := JSONCompetitor{
+		ID:           result.CompetitorID,
+		Status:       status(result.Status, result.Finished()),
+		TotalTimeMs:  result.TotalTime.Milliseconds(),
+		Laps:         result.Laps,
+		LapResults:   newJSONLaps(result.LapDurations, result.LapLengths),
+		SplitsMs:     make([]int64, len(result.SplitDurations)),
+		PenaltyLaps:  newJSONLaps(result.PenaltyDurations, result.PenaltyDistances),
+		Hits:         result.Hits,
+		Shots:        result.Shots,
+		Visits:       make([]JSONVisit, 0, len(result.Visits)),
+		SkippedLoops: result.SkippedLoops,
+	}
+	if result.Finished() {
+		competitor.FinishTime = result.FinishTime.Format("15:04:05.000")
+	}
+
+	for i, d := range result.SplitDurations {
+		competitor.SplitsMs[i] = d.Milliseconds()
+	}
+	var penaltyTime time.Duration
+	var penaltyDistance int
+	for i, d := range result.PenaltyDurations {
+		penaltyTime += d
+		penaltyDistance += result.PenaltyDistances[i]
+	}
+	competitor.PenaltyTimeMs = penaltyTime.Milliseconds()
+	if v, ok := speed(penaltyDistance, penaltyTime); ok {
+		competitor.PenaltySpeed = &v
+	}
+
+	for _, visit := range result.Visits {
+		competitor.Visits = append(competitor.Visits, JSONVisit{
+			Range:        visit.Range,
+			Lap:          visit.Lap + 1,
+			Position:     string(visit.Position),
+			Hits:         visit.Hits(),
+			Shots:        visit.Shots(),
+			SpareRounds:  visit.SpareRounds,
+			PenaltyLoops: visit.RequiredLoops(),
+			SkippedLoops: visit.SkippedLoops(),
+		})
+	}
+
+	return competitor
+}
+
+func newJSONLaps(durations []time.Duration, distances []int) []JSONLap {
+	laps := make([]JSONLap, len(durations))
+	for i, d := range durations {
+		laps[i] = JSONLap{TimeMs: d.Milliseconds(), Distance: distances[i]}
+		if v, ok := speed(distances[i], d); ok {
+			laps[i].Speed = &v
+		}
+	}
+	return laps
+}
+
+func newJSONTeam(team *utils.TeamResult) jsonTeam {
+	out := jsonTeam{
+		ID:           team.TeamID,
+		Status:       status(team.Status, team.Finished()),
+		TotalTimeMs:  team.TotalTime.Milliseconds(),
+		Legs:         make([]jsonLeg, len(team.Legs)),
+		PenaltyLoops: team.PenaltyLoops,
+		SpareRounds:  team.SpareRounds,
+	}
+
+	for i, athlete := range team.Legs {
+		out.Legs[i].Athlete = athlete
+		if team.LegTimes[i] != "" {
+			ms := team.LegDurations[i].Milliseconds()
+			out.Legs[i].TimeMs = &ms
+		}
+	}
+
+	return out
+}
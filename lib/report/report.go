@@ -0,0 +1,84 @@
+package report
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// Standings are the ranked results a Reporter writes out. Teams is only set
+// for relay formats.
+type Standings struct {
+	Format  configs.Format
+	Results []*utils.Result
+	Teams   []*utils.TeamResult
+}
+
+// NewStandings collects the current standings of the processor.
+func NewStandings(cfg *configs.Config, processor *utils.Processor) *Standings {
+	standings := &Standings{
+		Format:  cfg.Format,
+		Results: processor.Standings(),
+	}
+	if cfg.Format.IsRelay() {
+		standings.Teams = processor.TeamStandings()
+	}
+
+	return standings
+}
+
+// Reporter writes standings in one output format.
+type Reporter interface {
+	Report(w io.Writer, standings *Standings) error
+	// Extension is the file extension used for the format, without the dot.
+	Extension() string
+}
+
+// Names lists the formats known to New.
+func Names() []string {
+	return []string{"text", "json", "csv", "html"}
+}
+
+// New returns the reporter for the named format.
+func New(name string) (Reporter, bool) {
+	switch name {
+	case "text":
+		return TextReporter{}, true
+	case "json":
+		return JSONReporter{Indent: "  "}, true
+	case "csv":
+		return CSVReporter{}, true
+	case "html":
+		return HTMLReporter{}, true
+	default:
+		return nil, false
+	}
+}
+
+// status returns a machine-readable status of a competitor or team.
+func status(text string, finished bool) string {
+	switch {
+	case text == utils.StatusNotStarted:
+		return "not-started"
+	case text == utils.StatusNotFinished:
+		return "not-finished"
+	case text == utils.StatusDisqualified:
+		return "disqualified"
+	case finished:
+		return "finished"
+	default:
+		return "running"
+	}
+}
+
+// speed returns the average speed in m/s rounded like the text report, or
+// false for an empty interval.
+func speed(distance int, d time.Duration) (float64, bool) {
+	if d <= 0 {
+		return 0, false
+	}
+	return math.Round(float64(distance)/d.Seconds()*1000) / 1000, true
+}
@@ -0,0 +1,245 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/report"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func sprintStandings(t *testing.T) *report.Standings {
+	t.Helper()
+
+	cfg := &configs.Config{
+		Format:        configs.FormatSprint,
+		Laps:          2,
+		LapLength:     3000,
+		PenaltyLength: 150,
+		FiringLines:   1,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+	}
+
+	events := []utils.Event{
+		{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[09:30:00.000]", CompetitorID: 2, ID: 1},
+		{RawTime: "[09:30:00.000]", CompetitorID: 3, ID: 1},
+		{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		{RawTime: "[09:40:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:00.000"},
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:01:00.000]", CompetitorID: 2, ID: 4},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:05:01.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:05:02.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:05:03.000]", CompetitorID: 1, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:05:04.000]", CompetitorID: 1, ID: 6, ExtraParams: "4"},
+		{RawTime: "[10:05:10.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:05:15.000]", CompetitorID: 1, ID: 8},
+		{RawTime: "[10:05:45.000]", CompetitorID: 1, ID: 9},
+		{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:11:00.000]", CompetitorID: 2, ID: 10},
+		{RawTime: "[10:12:00.000]", CompetitorID: 2, ID: 11, ExtraParams: "Lost"},
+		{RawTime: "[10:20:00.000]", CompetitorID: 1, ID: 10},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+	processor.Close()
+
+	return report.NewStandings(cfg, processor)
+}
+
+// skippedLoopStandings is a sprint whose only competitor misses three targets
+// and skis a single 30-second penalty loop.
+func skippedLoopStandings(t *testing.T) *report.Standings {
+	t.Helper()
+
+	cfg := &configs.Config{
+		Format:        configs.FormatSprint,
+		Laps:          1,
+		LapLength:     3000,
+		PenaltyLength: 150,
+		PenaltySpeed:  5,
+		FiringLines:   1,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range []utils.Event{
+		{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:05:01.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:05:02.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:05:10.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:05:15.000]", CompetitorID: 1, ID: 8},
+		{RawTime: "[10:05:45.000]", CompetitorID: 1, ID: 9},
+		{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10},
+	} {
+		processor.Apply(event)
+	}
+	processor.Close()
+
+	return report.NewStandings(cfg, processor)
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range report.Names() {
+		reporter, ok := report.New(name)
+		require.True(t, ok, name)
+		assert.NotEmpty(t, reporter.Extension())
+	}
+
+	_, ok := report.New("pdf")
+	assert.False(t, ok)
+}
+
+func TestTextReporter(t *testing.T) {
+	standings := sprintStandings(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.TextReporter{}.Report(&buf, standings))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, len(standings.Results))
+	for i, result := range standings.Results {
+		assert.Equal(t, utils.FormatResult(result), lines[i])
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, report.JSONReporter{}.Report(&buf, sprintStandings(t)))
+
+	var got struct {
+		Format      string `json:"format"`
+		Competitors []struct {
+			ID          int    `json:"id"`
+			Status      string `json:"status"`
+			FinishTime  string `json:"finishTime"`
+			TotalTimeMs int64  `json:"totalTimeMs"`
+			LapResults  []struct {
+				TimeMs   int64    `json:"timeMs"`
+				Distance int      `json:"distance"`
+				Speed    *float64 `json:"speed"`
+			} `json:"lapResults"`
+			SplitsMs      []int64  `json:"splitsMs"`
+			PenaltyTimeMs int64    `json:"penaltyTimeMs"`
+			PenaltySpeed  *float64 `json:"penaltySpeed"`
+			Hits          int      `json:"hits"`
+			Shots         int      `json:"shots"`
+			Visits        []struct {
+				Position     string `json:"position"`
+				PenaltyLoops int    `json:"penaltyLoops"`
+			} `json:"visits"`
+		} `json:"competitors"`
+		Teams []json.RawMessage `json:"teams"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+
+	assert.Equal(t, "sprint", got.Format)
+	assert.Nil(t, got.Teams)
+	require.Len(t, got.Competitors, 3)
+
+	statuses := map[int]string{}
+	for _, competitor := range got.Competitors {
+		statuses[competitor.ID] = competitor.Status
+	}
+	assert.Equal(t, map[int]string{1: "finished", 2: "not-finished", 3: "running"}, statuses)
+
+	finished := got.Competitors[len(got.Competitors)-1]
+	assert.Equal(t, 1, finished.ID)
+	assert.Equal(t, "10:20:00.000", finished.FinishTime)
+	assert.Equal(t, int64(20*time.Minute/time.Millisecond), finished.TotalTimeMs)
+	assert.Equal(t, int64(30000), finished.PenaltyTimeMs)
+	require.NotNil(t, finished.PenaltySpeed)
+	assert.InDelta(t, 5.0, *finished.PenaltySpeed, 1e-9)
+	assert.Equal(t, 4, finished.Hits)
+	assert.Equal(t, 5, finished.Shots)
+	require.Len(t, finished.LapResults, 2)
+	assert.Equal(t, int64(600000), finished.LapResults[0].TimeMs)
+	assert.Equal(t, 3000, finished.LapResults[0].Distance)
+	require.NotNil(t, finished.LapResults[0].Speed)
+	assert.InDelta(t, 5.0, *finished.LapResults[0].Speed, 1e-9)
+	assert.Equal(t, []int64{600000, 1200000}, finished.SplitsMs)
+	require.Len(t, finished.Visits, 1)
+	assert.Equal(t, "prone", finished.Visits[0].Position)
+	assert.Equal(t, 1, finished.Visits[0].PenaltyLoops)
+}
+
+func TestJSONReporterSkippedLoops(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, report.JSONReporter{}.Report(&buf, skippedLoopStandings(t)))
+
+	assert.Contains(t, buf.String(), `"skippedLoops":2}`)
+	assert.Contains(t, buf.String(), `"spareRounds":0,"penaltyLoops":3,"skippedLoops":2}`,
+		"a visit reports the loops owed and the loops skipped")
+}
+
+func TestCSVReporter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, report.CSVReporter{}.Report(&buf, sprintStandings(t)))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	assert.Equal(t, []string{
+		"position", "id", "status", "finish_time", "total_time",
+		"lap_1_time", "lap_1_split", "lap_1_speed", "lap_2_time", "lap_2_split", "lap_2_speed",
+		"penalty_time", "penalty_speed", "hits", "shots", "skipped_loops",
+	}, records[0])
+	assert.Equal(t, []string{
+		"3", "1", "finished", "10:20:00.000", "00:20:00.000",
+		"00:10:00.000", "00:10:00.000", "5.000", "00:10:00.000", "00:20:00.000", "5.000",
+		"00:00:30.000", "5.000", "4", "5", "0",
+	}, records[3])
+}
+
+func TestRelayTeams(t *testing.T) {
+	cfg := &configs.Config{
+		Format:     configs.FormatRelay,
+		Laps:       1,
+		LapLength:  3000,
+		Start:      configs.Clock(10 * time.Hour),
+		StartDelta: configs.Duration(30 * time.Second),
+		Relay: configs.Relay{Teams: []configs.Team{
+			{ID: 1, Legs: []int{11, 12}},
+		}},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range []utils.Event{
+		{RawTime: "[09:50:00.000]", CompetitorID: 11, ID: 1},
+		{RawTime: "[09:50:00.000]", CompetitorID: 12, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 11, ID: 4},
+		{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: 10},
+		{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventHandOver, ExtraParams: "12"},
+	} {
+		processor.Apply(event)
+	}
+	standings := report.NewStandings(cfg, processor)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.JSONReporter{}.Report(&buf, standings))
+	assert.Contains(t, buf.String(),
+		`"teams":[{"id":1,"status":"running","totalTimeMs":480000,`+
+			`"legs":[{"athlete":11,"timeMs":480000},{"athlete":12,"timeMs":null}],"penaltyLoops":0,"spareRounds":0}]`)
+
+	buf.Reset()
+	require.NoError(t, report.CSVReporter{}.Report(&buf, standings))
+	assert.Contains(t, buf.String(), "\n\nposition,team,status,total_time,leg_1_athlete,leg_1_time,leg_2_athlete,leg_2_time,")
+	assert.Contains(t, buf.String(), "\n1,1,running,00:08:00.000,11,00:08:00.000,12,,0,0\n")
+}
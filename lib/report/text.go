@@ -0,0 +1,32 @@
+package report
+
+import (
+	"fmt"
+	"io"
+
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+// TextReporter writes the bracketed line format of result.txt: one line per
+// competitor followed by one line per relay team.
+type TextReporter struct{}
+
+func (TextReporter) Extension() string {
+	return "txt"
+}
+
+func (TextReporter) Report(w io.Writer, standings *Standings) error {
+	for _, result := range standings.Results {
+		if _, err := fmt.Fprintln(w, utils.FormatResult(result)); err != nil {
+			return err
+		}
+	}
+
+	for _, team := range standings.Teams {
+		if _, err := fmt.Fprintln(w, utils.FormatTeamResult(team)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"biathlon-competitions-prototype/configs"
+)
+
+// Identity is the holder of the token a request was made with.
+type Identity struct {
+	Name string
+	Role configs.Role
+}
+
+// anonymous is the identity of every request when no tokens are configured.
+func anonymous() Identity {
+	return Identity{Name: "anonymous", Role: configs.RoleJury}
+}
+
+type identityKey struct{}
+
+func identityFrom(ctx context.Context) Identity {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	if !ok {
+		return anonymous()
+	}
+	return identity
+}
+
+// authenticate finds the token of a request. It is taken from the
+// Authorization: Bearer header, or for reads from the token query parameter,
+// since browsers cannot set headers on an EventSource.
+func (s *server) authenticate(req *http.Request) (Identity, bool) {
+	if len(s.tokens) == 0 {
+		return anonymous(), true
+	}
+
+	secret, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok && req.Method == http.MethodGet {
+		secret = req.URL.Query().Get("token")
+	}
+	if secret == "" {
+		return Identity{}, false
+	}
+
+	found := -1
+	for i, token := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(token.Token)) == 1 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return Identity{}, false
+	}
+	return Identity{Name: s.tokens[found].Name, Role: s.tokens[found].Role}, true
+}
+
+// require lets a request through when its token has the role. Refused writes
+// are audit-logged.
+func (s *server) require(role configs.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		identity, ok := s.authenticate(req)
+		if !ok {
+			s.refuse(req, "", "missing or unknown token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="biathlon"`)
+			s.error(w, http.StatusUnauthorized, "a valid token is required")
+			return
+		}
+		if !identity.Role.Includes(role) {
+			s.refuse(req, identity.Name, "role "+string(identity.Role)+" is not allowed")
+			s.error(w, http.StatusForbidden, "the "+string(role)+" role is required")
+			return
+		}
+
+		next(w, req.WithContext(context.WithValue(req.Context(), identityKey{}, identity)))
+	}
+}
+
+func (s *server) refuse(req *http.Request, name string, reason string) {
+	if req.Method == http.MethodGet {
+		return
+	}
+	s.audit.Warn("write refused",
+		slog.String("token", name),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.String("remote", req.RemoteAddr),
+		slog.String("reason", reason),
+	)
+}
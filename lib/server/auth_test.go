@@ -0,0 +1,182 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/race"
+	"biathlon-competitions-prototype/lib/server"
+)
+
+const (
+	viewerToken = "viewer-0123456789"
+	timingToken = "timing-0123456789"
+	juryToken   = "jury-0123456789ab"
+)
+
+func newAuthHandler(t *testing.T) (http.Handler, *bytes.Buffer) {
+	t.Helper()
+
+	cfg := &configs.Config{
+		Format:        configs.FormatRelay,
+		Laps:          1,
+		LapLength:     3000,
+		PenaltyLength: 150,
+		FiringLines:   1,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+		Relay:         configs.Relay{SpareRounds: 3, Teams: []configs.Team{{ID: 1, Legs: []int{1, 2}}}},
+	}
+	tokens := []configs.Token{
+		{Name: "screen", Token: viewerToken, Role: configs.RoleViewer},
+		{Name: "range-1", Token: timingToken, Role: configs.RoleTiming},
+		{Name: "chief", Token: juryToken, Role: configs.RoleJury},
+	}
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logs, nil))
+	r := race.New(cfg, log)
+	t.Cleanup(r.Close)
+
+	return server.New(r, tokens, log), &logs
+}
+
+func serve(handler http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequestWithContext(context.Background(), method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func auditRecords(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		if record["log"] == "audit" {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestReadsNeedAToken(t *testing.T) {
+	handler, logs := newAuthHandler(t)
+
+	rec := serve(handler, http.MethodGet, "/standings", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="biathlon"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = serve(handler, http.MethodGet, "/standings", "wrong-token-0123456", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	for _, token := range []string{viewerToken, timingToken, juryToken} {
+		rec = serve(handler, http.MethodGet, "/standings", token, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	rec = serve(handler, http.MethodGet, "/log?token="+viewerToken, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Empty(t, auditRecords(t, logs), "reads are not audited")
+}
+
+func TestWritesNeedARole(t *testing.T) {
+	handler, logs := newAuthHandler(t)
+
+	rec := serve(handler, http.MethodPost, "/events?token="+timingToken, "", "[09:30:00.000] 1 1\n")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "the token parameter is only read for GET")
+
+	rec = serve(handler, http.MethodPost, "/events", viewerToken, "[09:30:00.000] 1 1\n")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve(handler, http.MethodPost, "/events", timingToken, "[09:30:00.000] 1 1\n")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	records := auditRecords(t, logs)
+	require.Len(t, records, 3)
+	assert.Equal(t, "write refused", records[0]["msg"])
+	assert.Equal(t, "", records[0]["token"])
+	assert.Equal(t, "write refused", records[1]["msg"])
+	assert.Equal(t, "screen", records[1]["token"])
+	assert.Equal(t, "event accepted", records[2]["msg"])
+	assert.Equal(t, "range-1", records[2]["token"])
+	assert.Equal(t, "timing", records[2]["role"])
+	assert.Equal(t, "[09:30:00.000] 1 1", records[2]["event"])
+}
+
+func TestTimingSubmitsRelayEvents(t *testing.T) {
+	handler, logs := newAuthHandler(t)
+
+	events := "[09:30:00.000] 1 1\n[09:30:00.000] 1 2\n[10:00:00.000] 4 1\n" +
+		"[10:05:00.000] 5 1 1\n[10:05:01.000] 13 1\n[10:05:10.000] 7 1\n[10:10:00.000] 10 1\n[10:10:00.000] 12 1 2\n"
+
+	rec := serve(handler, http.MethodPost, "/events", timingToken, events)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	records := auditRecords(t, logs)
+	require.Len(t, records, 8)
+	for _, record := range records {
+		assert.Equal(t, "event accepted", record["msg"])
+		assert.Equal(t, "range-1", record["token"])
+	}
+}
+
+func TestOnlyTheJuryDisqualifies(t *testing.T) {
+	handler, logs := newAuthHandler(t)
+
+	events := "[09:30:00.000] 1 1\n[09:40:00.000] 14 1 Shortcut\n"
+
+	rec := serve(handler, http.MethodPost, "/events", timingToken, events)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "event 14")
+
+	rec = serve(handler, http.MethodGet, "/log", juryToken, "")
+	assert.JSONEq(t, `{"lines": [], "next": 0}`, rec.Body.String(), "a refused batch must not be applied")
+
+	rec = serve(handler, http.MethodPost, "/events", juryToken, events)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"outgoing":[{"time":"09:40:00.000","id":32,"competitor":1}]`)
+
+	rec = serve(handler, http.MethodGet, "/log?since=1", juryToken, "")
+	assert.Contains(t, rec.Body.String(), "The competitor(1) is disqualified by the jury: Shortcut")
+
+	rec = serve(handler, http.MethodPost, "/events", juryToken, "[09:45:00.000] 15 1\n")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the race still rejects unknown events")
+	assert.Contains(t, rec.Body.String(), "unknown event id 15")
+
+	records := auditRecords(t, logs)
+	require.Len(t, records, 4)
+	assert.Equal(t, "write refused", records[0]["msg"])
+	assert.Equal(t, "range-1", records[0]["token"])
+	assert.Equal(t, "event accepted", records[1]["msg"])
+	assert.Equal(t, "event accepted", records[2]["msg"])
+	assert.Equal(t, "chief", records[2]["token"])
+	assert.Equal(t, "jury", records[2]["role"])
+	assert.Equal(t, "[09:40:00.000] 14 1 Shortcut", records[2]["event"])
+	assert.InDelta(t, 1, records[2]["outgoing"], 0)
+	assert.Equal(t, "event rejected", records[3]["msg"])
+}
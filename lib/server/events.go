@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/logger/sl"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+const maxEventsBody = 1 << 20
+
+type eventJSON struct {
+	Time         string `json:"time"`
+	ID           int    `json:"id"`
+	CompetitorID int    `json:"competitor"`
+	Params       string `json:"params,omitempty"`
+}
+
+func newEventJSON(event utils.Event) eventJSON {
+	return eventJSON{
+		Time:         strings.Trim(event.RawTime, "[]"),
+		ID:           event.ID,
+		CompetitorID: event.CompetitorID,
+		Params:       event.ExtraParams,
+	}
+}
+
+// submitResult is the outcome of one submitted event: the outgoing events it
+// produced, or the reason it was rejected.
+type submitResult struct {
+	Event    eventJSON   `json:"event"`
+	Accepted bool        `json:"accepted"`
+	Outgoing []eventJSON `json:"outgoing"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type submitResponse struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Results  []submitResult `json:"results"`
+}
+
+// submitEvents applies a batch of events in order. Every event is validated on
+// its own, so a rejected event does not stop the ones after it. The response
+// is 200 when all events were accepted and 422 otherwise. When the race no
+// longer takes events, e.g. after a journal write failed, the batch stops with
+// 503.
+func (s *server) submitEvents(w http.ResponseWriter, req *http.Request) {
+	events, err := decodeEvents(w, req)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.error(w, http.StatusRequestEntityTooLarge, "request body is too large")
+			return
+		}
+		s.error(w, http.StatusBadRequest, fmt.Sprintf("cannot parse events: %s", err))
+		return
+	}
+	if len(events) == 0 {
+		s.error(w, http.StatusBadRequest, "no events given")
+		return
+	}
+
+	identity := identityFrom(req.Context())
+	if !identity.Role.Includes(configs.RoleJury) {
+		for _, event := range events {
+			if !isTimingEvent(event.ID, s.race.Format()) {
+				s.refuse(req, identity.Name, fmt.Sprintf("event %d needs the jury role", event.ID))
+				s.error(w, http.StatusForbidden, fmt.Sprintf(
+					"the %s role may not submit event %d", identity.Role, event.ID,
+				))
+				return
+			}
+		}
+	}
+
+	response := submitResponse{Results: make([]submitResult, len(events))}
+	for i, event := range events {
+		result := submitResult{Event: newEventJSON(event), Outgoing: []eventJSON{}}
+
+		applied, err := s.race.Apply(event)
+		attrs := []any{
+			slog.String("token", identity.Name),
+			slog.String("role", string(identity.Role)),
+			slog.String("remote", req.RemoteAddr),
+			slog.String("event", event.String()),
+		}
+		var validationErr *utils.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			response.Rejected++
+			result.Error = err.Error()
+			s.audit.Info("event rejected", append(attrs, sl.Err(err))...)
+		case err != nil:
+			s.audit.Error("event not recorded", append(attrs, sl.Err(err))...)
+			s.error(w, http.StatusServiceUnavailable, "the race does not take events: "+err.Error())
+			return
+		default:
+			s.audit.Info("event accepted", append(attrs, slog.Int("outgoing", len(applied.Outgoing)))...)
+			response.Accepted++
+			result.Accepted = true
+			for _, outgoing := range applied.Outgoing {
+				result.Outgoing = append(result.Outgoing, newEventJSON(outgoing))
+			}
+			for _, warning := range applied.Warnings {
+				result.Warnings = append(result.Warnings, warning.String())
+			}
+		}
+
+		response.Results[i] = result
+	}
+
+	status := http.StatusOK
+	if response.Rejected > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	s.json(w, status, response)
+}
+
+// decodeEvents reads the events of a request in the format of its content
+// type: application/json takes one event object or an array of them, the
+// other formats are those of the events file. Without a content type the
+// format is detected.
+func decodeEvents(w http.ResponseWriter, req *http.Request) ([]utils.Event, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxEventsBody))
+	if err != nil {
+		return nil, err
+	}
+
+	format := utils.EventFormatAuto
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		switch mediaType {
+		case "application/json":
+			return utils.UnmarshalJSONEvents(body)
+		case "application/jsonl", "application/x-ndjson":
+			format = utils.EventFormatJSONL
+		case "text/csv":
+			format = utils.EventFormatCSV
+		case "text/plain":
+			format = utils.EventFormatText
+		}
+	}
+
+	decoder, err := utils.NewEventDecoder(bytes.NewReader(body), format, "")
+	if err != nil {
+		return nil, err
+	}
+	return utils.DecodeEvents(decoder)
+}
+
+// isTimingEvent reports whether the event is recorded by the timing devices:
+// the incoming events 1-11, and in relays the hand-overs of the exchange zone
+// and the spare rounds of the firing range. Any other event, such as a jury
+// disqualification, needs the jury role.
+func isTimingEvent(id int, format configs.Format) bool {
+	if id == utils.EventHandOver || id == utils.EventSpareRound {
+		return format.IsRelay()
+	}
+	return id >= 1 && id <= 11
+}
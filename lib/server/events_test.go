@@ -0,0 +1,130 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type submitResponse struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Results  []struct {
+		Event struct {
+			Time       string `json:"time"`
+			ID         int    `json:"id"`
+			Competitor int    `json:"competitor"`
+			Params     string `json:"params"`
+		} `json:"event"`
+		Accepted bool `json:"accepted"`
+		Outgoing []struct {
+			ID         int    `json:"id"`
+			Time       string `json:"time"`
+			Competitor int    `json:"competitor"`
+		} `json:"outgoing"`
+		Warnings []string `json:"warnings"`
+		Error    string   `json:"error"`
+	} `json:"results"`
+}
+
+func post(t *testing.T, url, contentType, body string) (int, submitResponse, string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var out submitResponse
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnprocessableEntity {
+		require.NoError(t, json.Unmarshal(data, &out), string(data))
+	}
+	return resp.StatusCode, out, string(data)
+}
+
+func TestSubmitTextEvents(t *testing.T) {
+	r, srv := newServer(t)
+
+	status, out, _ := post(t, srv.URL+"/events", "text/plain", `[09:30:00.000] 1 1
+[09:40:00.000] 2 1 10:00:00.000
+[10:00:00.000] 4 1
+`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, out.Accepted)
+	require.Len(t, out.Results, 3)
+	assert.Equal(t, "10:00:00.000", out.Results[1].Event.Params)
+	for _, result := range out.Results {
+		assert.True(t, result.Accepted)
+		assert.Empty(t, result.Outgoing)
+	}
+
+	status, out, _ = post(t, srv.URL+"/events", "", "[10:10:00.000] 10 1\n")
+	assert.Equal(t, http.StatusOK, status)
+	require.Len(t, out.Results, 1)
+	require.Len(t, out.Results[0].Outgoing, 1)
+	assert.Equal(t, 33, out.Results[0].Outgoing[0].ID)
+	assert.Equal(t, "10:10:00.000", out.Results[0].Outgoing[0].Time)
+	assert.Equal(t, 1, out.Results[0].Outgoing[0].Competitor)
+
+	assert.Len(t, r.Log(0), 5)
+}
+
+func TestSubmitJSONEvents(t *testing.T) {
+	_, srv := newServer(t)
+
+	status, out, _ := post(t, srv.URL+"/events", "application/json", `{"time": "09:30:00.000", "id": 1, "competitor": 1}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, out.Accepted)
+
+	status, out, _ = post(t, srv.URL+"/events", "application/json; charset=utf-8", `[
+		{"time": "09:40:00.000", "id": 2, "competitor": 1, "params": "10:00:00.000"},
+		{"time": "09:41:00.000", "id": 1, "competitor": 1}
+	]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, out.Accepted)
+	require.Len(t, out.Results[1].Warnings, 1)
+	assert.Contains(t, out.Results[1].Warnings[0], "duplicate-registration")
+}
+
+func TestSubmitRejectsInvalidEvents(t *testing.T) {
+	r, srv := newServer(t)
+	register(t, r)
+
+	status, out, _ := post(t, srv.URL+"/events", "text/plain", `[09:50:00.000] 4 2
+[10:00:00.000] 4 1
+`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, 1, out.Accepted)
+	assert.Equal(t, 1, out.Rejected)
+	assert.False(t, out.Results[0].Accepted)
+	assert.Contains(t, out.Results[0].Error, "unregistered")
+	assert.True(t, out.Results[1].Accepted)
+	assert.Len(t, r.Log(0), 3)
+}
+
+func TestSubmitMalformedEvents(t *testing.T) {
+	_, srv := newServer(t)
+
+	status, _, body := post(t, srv.URL+"/events", "application/json", `{"id": 1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "missing time")
+
+	status, _, _ = post(t, srv.URL+"/events", "text/plain", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _, _ = post(t, srv.URL+"/events", "text/plain", strings.Repeat("x", 2<<20))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+}
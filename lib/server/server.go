@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/logger/sl"
+	"biathlon-competitions-prototype/lib/race"
+	"biathlon-competitions-prototype/lib/report"
+)
+
+// HeartbeatInterval is how often an idle event stream sends a comment, so
+// proxies and clients keep the connection open.
+const HeartbeatInterval = 15 * time.Second
+
+type server struct {
+	race   *race.Race
+	tokens []configs.Token
+	log    *slog.Logger
+	audit  *slog.Logger
+}
+
+// New returns the HTTP API of the race:
+//
+//	GET /standings         the results, ?format= text, json (default), csv or html
+//	GET /competitors/{id}  the result and the course position of a competitor
+//	GET /log               the output log, ?since= skips the lines already seen
+//	GET /stream            Server-Sent Events with log lines, outgoing events and ranking changes
+//	POST /events           submit events, the response lists the outgoing events of each
+//
+// With tokens every request needs one: reads need the viewer role and
+// submitting events the timing role. Without tokens the API is open. Every
+// write is audit-logged with the name of its token.
+func New(r *race.Race, tokens []configs.Token, log *slog.Logger) http.Handler {
+	s := &server{
+		race:   r,
+		tokens: tokens,
+		log:    log,
+		audit:  log.With(slog.String("log", "audit")),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /standings", s.require(configs.RoleViewer, s.standings))
+	mux.HandleFunc("GET /competitors/{id}", s.require(configs.RoleViewer, s.competitor))
+	mux.HandleFunc("GET /log", s.require(configs.RoleViewer, s.outputLog))
+	mux.HandleFunc("GET /stream", s.require(configs.RoleViewer, s.stream))
+	mux.HandleFunc("POST /events", s.require(configs.RoleTiming, s.submitEvents))
+
+	return mux
+}
+
+func (s *server) standings(w http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	reporter, ok := report.New(format)
+	if !ok {
+		s.error(w, http.StatusBadRequest, fmt.Sprintf(
+			"unknown format %q, expected one of %s", format, strings.Join(report.Names(), ", "),
+		))
+		return
+	}
+
+	body, err := s.race.Report(reporter)
+	if err != nil {
+		s.log.Error("cannot build standings", sl.Err(err))
+		s.error(w, http.StatusInternalServerError, "cannot build standings")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType(reporter.Extension()))
+	_, _ = w.Write(body)
+}
+
+func (s *server) competitor(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("id"))
+	if err != nil {
+		s.error(w, http.StatusBadRequest, "competitor id must be a number")
+		return
+	}
+
+	detail, ok := s.race.Competitor(id)
+	if !ok {
+		s.error(w, http.StatusNotFound, fmt.Sprintf("competitor %d not found", id))
+		return
+	}
+
+	s.json(w, http.StatusOK, detail)
+}
+
+type logResponse struct {
+	Lines []string `json:"lines"`
+	// Next is the since value that returns only the lines added later.
+	Next int `json:"next"`
+}
+
+func (s *server) outputLog(w http.ResponseWriter, req *http.Request) {
+	since := 0
+	if value := req.URL.Query().Get("since"); value != "" {
+		var err error
+		since, err = strconv.Atoi(value)
+		if err != nil || since < 0 {
+			s.error(w, http.StatusBadRequest, "since must be a non-negative number")
+			return
+		}
+	}
+
+	lines := s.race.Log(since)
+	s.json(w, http.StatusOK, logResponse{Lines: lines, Next: since + len(lines)})
+}
+
+// stream sends the current ranking and then every update of the race until the
+// client goes away or the race is closed.
+func (s *server) stream(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.error(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	updates, unsubscribe := s.race.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeEvent(w, race.UpdateRanking, s.race.Ranking()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case update, open := <-updates:
+			if !open {
+				return
+			}
+			if err := writeEvent(w, update.Kind, update.Data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, kind string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, body)
+	return err
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *server) error(w http.ResponseWriter, status int, message string) {
+	s.json(w, status, errorResponse{Error: message})
+}
+
+func (s *server) json(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.log.Debug("cannot write response", sl.Err(err))
+	}
+}
+
+func contentType(extension string) string {
+	switch extension {
+	case "json":
+		return "application/json"
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "html":
+		return "text/html; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
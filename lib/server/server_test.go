@@ -0,0 +1,198 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/logger/slogdiscard"
+	"biathlon-competitions-prototype/lib/race"
+	"biathlon-competitions-prototype/lib/server"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func newServer(t *testing.T) (*race.Race, *httptest.Server) {
+	t.Helper()
+
+	cfg := &configs.Config{
+		Format:        configs.FormatSprint,
+		Laps:          1,
+		LapLength:     3000,
+		PenaltyLength: 150,
+		FiringLines:   1,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+	}
+	log := slogdiscard.NewDiscardLogger()
+	r := race.New(cfg, log)
+
+	srv := httptest.NewServer(server.New(r, nil, log))
+	t.Cleanup(func() {
+		r.Close()
+		srv.Close()
+	})
+
+	return r, srv
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body strings.Builder
+	_, err = bufio.NewReader(resp.Body).WriteTo(&body)
+	require.NoError(t, err)
+
+	return resp, body.String()
+}
+
+func apply(t *testing.T, r *race.Race, events ...utils.Event) {
+	t.Helper()
+
+	for _, event := range events {
+		_, err := r.Apply(event)
+		require.NoError(t, err)
+	}
+}
+
+func register(t *testing.T, r *race.Race) {
+	t.Helper()
+
+	apply(t, r,
+		utils.Event{RawTime: "[09:30:00.000]", CompetitorID: 1, ID: 1},
+		utils.Event{RawTime: "[09:40:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:00.000"},
+	)
+}
+
+func TestStandings(t *testing.T) {
+	r, srv := newServer(t)
+	register(t, r)
+
+	resp, body := get(t, srv.URL+"/standings")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var standings struct {
+		Competitors []struct {
+			ID     int    `json:"id"`
+			Status string `json:"status"`
+		} `json:"competitors"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &standings))
+	require.Len(t, standings.Competitors, 1)
+	assert.Equal(t, 1, standings.Competitors[0].ID)
+
+	resp, body = get(t, srv.URL+"/standings?format=csv")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(body, "position,id,"), body)
+
+	resp, _ = get(t, srv.URL+"/standings?format=pdf")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCompetitor(t *testing.T) {
+	r, srv := newServer(t)
+	register(t, r)
+
+	resp, body := get(t, srv.URL+"/competitors/1")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var detail race.CompetitorDetail
+	require.NoError(t, json.Unmarshal([]byte(body), &detail))
+	assert.Equal(t, 1, detail.ID)
+	assert.True(t, detail.Registered)
+	assert.Equal(t, "10:00:00.000", detail.PlannedStart)
+
+	resp, _ = get(t, srv.URL+"/competitors/2")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = get(t, srv.URL+"/competitors/abc")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLog(t *testing.T) {
+	r, srv := newServer(t)
+	register(t, r)
+
+	var log struct {
+		Lines []string `json:"lines"`
+		Next  int      `json:"next"`
+	}
+
+	_, body := get(t, srv.URL+"/log")
+	require.NoError(t, json.Unmarshal([]byte(body), &log))
+	assert.Len(t, log.Lines, 2)
+	assert.Equal(t, 2, log.Next)
+
+	apply(t, r, utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4})
+
+	_, body = get(t, srv.URL+"/log?since=2")
+	require.NoError(t, json.Unmarshal([]byte(body), &log))
+	assert.Equal(t, []string{"[10:00:00.000] The competitor(1) has started"}, log.Lines)
+	assert.Equal(t, 3, log.Next)
+
+	resp, _ := get(t, srv.URL+"/log?since=-1")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestStream(t *testing.T) {
+	r, srv := newServer(t)
+	register(t, r)
+	apply(t, r, utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	scanner := bufio.NewScanner(resp.Body)
+	next := func() (string, string) {
+		var kind, data string
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "" && kind != "":
+				return kind, data
+			case strings.HasPrefix(line, "event: "):
+				kind = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		t.Fatal("the stream ended")
+		return "", ""
+	}
+
+	kind, data := next()
+	assert.Equal(t, race.UpdateRanking, kind)
+	assert.JSONEq(t, `{"competitors":[1]}`, data)
+
+	apply(t, r, utils.Event{RawTime: "[10:10:00.000]", CompetitorID: 1, ID: 10})
+
+	kind, data = next()
+	assert.Equal(t, race.UpdateLog, kind)
+	assert.JSONEq(t, `{"index":3,"line":"[10:10:00.000] The competitor(1) ended the main lap"}`, data)
+
+	for kind != race.UpdateOutgoing {
+		kind, data = next()
+	}
+	assert.JSONEq(t, `{"id":33,"time":"10:10:00.000","competitor":1}`, data)
+}
@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EventFormat is an input format for events.
+type EventFormat string
+
+const (
+	// EventFormatAuto detects the format from the file extension or the first line.
+	EventFormatAuto EventFormat = "auto"
+	// EventFormatText is the "[time] id competitor params" format.
+	EventFormatText EventFormat = "text"
+	// EventFormatJSONL is one {"time", "id", "competitor", "params"} object per line.
+	EventFormatJSONL EventFormat = "jsonl"
+	// EventFormatCSV is time,id,competitor,params with an optional header row.
+	EventFormatCSV EventFormat = "csv"
+)
+
+// ParseEventFormat checks the name of an event format.
+func ParseEventFormat(name string) (EventFormat, error) {
+	switch format := EventFormat(name); format {
+	case EventFormatAuto, EventFormatText, EventFormatJSONL, EventFormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown event format %q, expected auto, text, jsonl or csv", name)
+	}
+}
+
+// EventDecoder reads events one at a time. Decode returns io.EOF after the
+// last event. Every decoder produces the same Event values for the same input:
+// RawTime keeps the brackets and ExtraParams is only set for events that take
+// parameters.
+type EventDecoder interface {
+	Decode() (Event, error)
+}
+
+// NewEventDecoder returns a decoder for the format. With EventFormatAuto the
+// format is detected from the extension of name, which may be empty, or else
+// from the first line of r.
+func NewEventDecoder(r io.Reader, format EventFormat, name string) (EventDecoder, error) {
+	in := bufio.NewReader(r)
+
+	if format == EventFormatAuto {
+		format = formatByExtension(name)
+	}
+	if format == EventFormatAuto {
+		format = sniffEventFormat(in)
+	}
+
+	switch format {
+	case EventFormatText:
+		return NewTextDecoder(in), nil
+	case EventFormatJSONL:
+		return NewJSONLDecoder(in), nil
+	case EventFormatCSV:
+		return NewCSVDecoder(in), nil
+	default:
+		return nil, fmt.Errorf("unknown event format %q", format)
+	}
+}
+
+// DecodeEvents reads every event from the decoder.
+func DecodeEvents(decoder EventDecoder) ([]Event, error) {
+	var events []Event
+	for {
+		event, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+func formatByExtension(name string) EventFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return EventFormatJSONL
+	case ".csv":
+		return EventFormatCSV
+	default:
+		return EventFormatAuto
+	}
+}
+
+var textLine = regexp.MustCompile(`^\[[^\],]*\]\s`)
+
+// sniffEventFormat looks at the first line without consuming it. It peeks one
+// byte at a time, so on a live stream it only waits for the first line.
+func sniffEventFormat(in *bufio.Reader) EventFormat {
+	var peeked []byte
+	for n := 1; n <= in.Size(); n++ {
+		var err error
+		peeked, err = in.Peek(n)
+		if err != nil || peeked[n-1] == '\n' {
+			break
+		}
+	}
+	line := bytes.TrimSpace(bytes.TrimPrefix(peeked, []byte("\uFEFF")))
+
+	switch {
+	case bytes.HasPrefix(line, []byte("{")):
+		return EventFormatJSONL
+	case textLine.Match(line):
+		return EventFormatText
+	case bytes.IndexByte(line, ',') >= 0:
+		return EventFormatCSV
+	default:
+		return EventFormatText
+	}
+}
+
+func hasExtraParams(id int) bool {
+	return id == 2 || id == 5 || id == 6 || id == 11 || id == EventHandOver || id == EventJuryDisqualification
+}
+
+// newEvent builds an event the way the text format reads it.
+func newEvent(rawTime string, id int, competitorID int, extraParams string) Event {
+	if !strings.HasPrefix(rawTime, "[") {
+		rawTime = "[" + rawTime + "]"
+	}
+	if !hasExtraParams(id) {
+		extraParams = ""
+	}
+
+	return Event{RawTime: rawTime, ID: id, CompetitorID: competitorID, ExtraParams: extraParams}
+}
+
+// TextDecoder reads the whitespace-separated text format. An event that is cut
+// off at the end of the input is dropped.
+type TextDecoder struct {
+	in *bufio.Reader
+}
+
+func NewTextDecoder(r io.Reader) *TextDecoder {
+	in, ok := r.(*bufio.Reader)
+	if !ok {
+		in = bufio.NewReader(r)
+	}
+	return &TextDecoder{in: in}
+}
+
+func (d *TextDecoder) Decode() (Event, error) {
+	var rawEventTime, extraParams string
+	var competitorID, eventID int
+
+	if _, err := fmt.Fscan(d.in, &rawEventTime, &eventID, &competitorID); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Event{}, io.EOF
+		}
+		return Event{}, fmt.Errorf("error while scanning: %w", err)
+	}
+
+	if hasExtraParams(eventID) {
+		if _, err := fmt.Fscan(d.in, &extraParams); err != nil {
+			if errors.Is(err, io.EOF) {
+				return Event{}, io.EOF
+			}
+			return Event{}, fmt.Errorf("error while scanning extra params: %w", err)
+		}
+	}
+
+	return Event{
+		RawTime:      rawEventTime,
+		ID:           eventID,
+		CompetitorID: competitorID,
+		ExtraParams:  extraParams,
+	}, nil
+}
+
+// JSONLDecoder reads one JSON object per line. The time may be given with or
+// without brackets and params may be a string or a number. Blank lines are
+// skipped.
+type JSONLDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+type jsonEvent struct {
+	Time         string          `json:"time"`
+	ID           int             `json:"id"`
+	CompetitorID int             `json:"competitor"`
+	Params       json.RawMessage `json:"params"`
+}
+
+func NewJSONLDecoder(r io.Reader) *JSONLDecoder {
+	return &JSONLDecoder{scanner: bufio.NewScanner(r)}
+}
+
+func (d *JSONLDecoder) Decode() (Event, error) {
+	for d.scanner.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var raw jsonEvent
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", d.line, err)
+		}
+
+		event, err := raw.event()
+		if err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", d.line, err)
+		}
+		return event, nil
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+func (raw jsonEvent) event() (Event, error) {
+	if raw.Time == "" {
+		return Event{}, errors.New("missing time")
+	}
+
+	params, err := jsonParams(raw.Params)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return newEvent(raw.Time, raw.ID, raw.CompetitorID, params), nil
+}
+
+// UnmarshalJSONEvents decodes a single JSON event object or an array of them,
+// with the fields of the JSON Lines format.
+func UnmarshalJSONEvents(data []byte) ([]Event, error) {
+	data = bytes.TrimSpace(data)
+
+	var raws []jsonEvent
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+	} else {
+		var raw jsonEvent
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		raws = append(raws, raw)
+	}
+
+	events := make([]Event, len(raws))
+	for i, raw := range raws {
+		event, err := raw.event()
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+		events[i] = event
+	}
+	return events, nil
+}
+
+func jsonParams(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var params string
+	if err := json.Unmarshal(raw, &params); err == nil {
+		return params, nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return "", fmt.Errorf("params must be a string or a number, got %s", raw)
+	}
+	return number.String(), nil
+}
+
+// CSVDecoder reads time,id,competitor[,params] records. A first row whose id
+// column is not a number is taken as a header.
+type CSVDecoder struct {
+	reader *csv.Reader
+	first  bool
+}
+
+func NewCSVDecoder(r io.Reader) *CSVDecoder {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	return &CSVDecoder{reader: reader, first: true}
+}
+
+func (d *CSVDecoder) Decode() (Event, error) {
+	for {
+		record, err := d.reader.Read()
+		if err != nil {
+			return Event{}, err
+		}
+		line, _ := d.reader.FieldPos(0)
+
+		first := d.first
+		d.first = false
+
+		if len(record) < 3 {
+			return Event{}, fmt.Errorf("line %d: expected time, id and competitor, got %d fields", line, len(record))
+		}
+
+		id, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			if first {
+				continue
+			}
+			return Event{}, fmt.Errorf("line %d: invalid event id %q", line, record[1])
+		}
+
+		competitorID, err := strconv.Atoi(strings.TrimSpace(record[2]))
+		if err != nil {
+			return Event{}, fmt.Errorf("line %d: invalid competitor id %q", line, record[2])
+		}
+
+		var params string
+		if len(record) > 3 {
+			params = strings.TrimSpace(record[3])
+		}
+
+		return newEvent(strings.TrimSpace(record[0]), id, competitorID, params), nil
+	}
+}
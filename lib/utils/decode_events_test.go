@@ -0,0 +1,180 @@
+package utils_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+var decodedEvents = []utils.Event{
+	{RawTime: "[09:05:59.867]", ID: 1, CompetitorID: 1},
+	{RawTime: "[09:15:00.841]", ID: 2, CompetitorID: 1, ExtraParams: "09:30:00.000"},
+	{RawTime: "[09:31:49.285]", ID: 5, CompetitorID: 1, ExtraParams: "1"},
+	{RawTime: "[09:31:50.000]", ID: 6, CompetitorID: 1, ExtraParams: "3"},
+	{RawTime: "[09:59:03.872]", ID: 10, CompetitorID: 1},
+	{RawTime: "[10:00:00.000]", ID: 11, CompetitorID: 1, ExtraParams: "Lost"},
+}
+
+func TestEventDecoders(t *testing.T) {
+	tests := []struct {
+		name   string
+		format utils.EventFormat
+		input  string
+	}{
+		{
+			name:   "text",
+			format: utils.EventFormatText,
+			input: `[09:05:59.867] 1 1
+[09:15:00.841] 2 1 09:30:00.000
+[09:31:49.285] 5 1 1
+[09:31:50.000] 6 1 3
+[09:59:03.872] 10 1
+[10:00:00.000] 11 1 Lost
+`,
+		},
+		{
+			name:   "jsonl",
+			format: utils.EventFormatJSONL,
+			input: `{"time": "09:05:59.867", "id": 1, "competitor": 1}
+{"time": "[09:15:00.841]", "id": 2, "competitor": 1, "params": "09:30:00.000"}
+
+{"time": "09:31:49.285", "id": 5, "competitor": 1, "params": 1}
+{"time": "09:31:50.000", "id": 6, "competitor": 1, "params": "3"}
+{"time": "09:59:03.872", "id": 10, "competitor": 1, "params": null}
+{"time": "10:00:00.000", "id": 11, "competitor": 1, "params": "Lost"}
+`,
+		},
+		{
+			name:   "csv",
+			format: utils.EventFormatCSV,
+			input: `time,id,competitor,params
+09:05:59.867,1,1
+[09:15:00.841],2,1,09:30:00.000
+09:31:49.285,5,1,1
+09:31:50.000, 6, 1, 3
+09:59:03.872,10,1,
+10:00:00.000,11,1,"Lost"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []utils.EventFormat{tt.format, utils.EventFormatAuto} {
+				decoder, err := utils.NewEventDecoder(strings.NewReader(tt.input), format, "")
+				require.NoError(t, err)
+
+				events, err := utils.DecodeEvents(decoder)
+				require.NoError(t, err, format)
+				assert.Equal(t, decodedEvents, events, format)
+			}
+		})
+	}
+}
+
+func TestNewEventDecoderDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		fileName string
+		expected any
+	}{
+		{name: "text", input: "[09:05:59.867] 1 1\n", expected: &utils.TextDecoder{}},
+		{name: "text with leading space", input: "  [09:05:59.867] 1 1", expected: &utils.TextDecoder{}},
+		{name: "empty", input: "", expected: &utils.TextDecoder{}},
+		{name: "jsonl", input: `{"time":"09:05:59.867","id":1,"competitor":1}`, expected: &utils.JSONLDecoder{}},
+		{name: "csv", input: "[09:05:59.867],1,1\n", expected: &utils.CSVDecoder{}},
+		{name: "csv header", input: "time,id,competitor\n", expected: &utils.CSVDecoder{}},
+		{name: "csv extension", input: "time;id", fileName: "events.CSV", expected: &utils.CSVDecoder{}},
+		{name: "jsonl extension", input: "", fileName: "race.ndjson", expected: &utils.JSONLDecoder{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := utils.NewEventDecoder(strings.NewReader(tt.input), utils.EventFormatAuto, tt.fileName)
+			require.NoError(t, err)
+			assert.IsType(t, tt.expected, decoder)
+		})
+	}
+
+	_, err := utils.NewEventDecoder(strings.NewReader(""), "xml", "")
+	require.Error(t, err)
+}
+
+func TestEventDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format utils.EventFormat
+		input  string
+	}{
+		{name: "jsonl syntax", format: utils.EventFormatJSONL, input: "{\"time\": \"09:00:00.000\", \"id\": 1,"},
+		{name: "jsonl missing time", format: utils.EventFormatJSONL, input: `{"id": 1, "competitor": 1}`},
+		{name: "jsonl params", format: utils.EventFormatJSONL, input: `{"time": "09:00:00.000", "id": 5, "params": [1]}`},
+		{name: "csv short", format: utils.EventFormatCSV, input: "09:00:00.000,1\n"},
+		{name: "csv event id", format: utils.EventFormatCSV, input: "09:00:00.000,1,1\n09:00:00.000,x,1\n"},
+		{name: "csv competitor", format: utils.EventFormatCSV, input: "09:00:00.000,1,x\n"},
+		{name: "text", format: utils.EventFormatText, input: "[09:00:00.000] x 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := utils.ReadEventsAs(strings.NewReader(tt.input), tt.format, "")
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDecoderReturnsEOF(t *testing.T) {
+	decoder := utils.NewJSONLDecoder(strings.NewReader(`{"time": "09:00:00.000", "id": 1, "competitor": 1}`))
+
+	_, err := decoder.Decode()
+	require.NoError(t, err)
+	_, err = decoder.Decode()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestReadEventsDetectsExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"time": "09:05:59.867", "id": 1, "competitor": 1}`), 0600))
+
+	events, err := utils.ReadEvents(path)
+	require.NoError(t, err)
+	assert.Equal(t, decodedEvents[:1], events)
+}
+
+func TestParseEventFormat(t *testing.T) {
+	format, err := utils.ParseEventFormat("jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, utils.EventFormatJSONL, format)
+
+	_, err = utils.ParseEventFormat("yaml")
+	require.Error(t, err)
+}
+
+func TestUnmarshalJSONEvents(t *testing.T) {
+	events, err := utils.UnmarshalJSONEvents([]byte(`{"time": "09:05:59.867", "id": 1, "competitor": 1}`))
+	require.NoError(t, err)
+	assert.Equal(t, decodedEvents[:1], events)
+
+	events, err = utils.UnmarshalJSONEvents([]byte(`[
+		{"time": "[09:05:59.867]", "id": 1, "competitor": 1},
+		{"time": "09:15:00.841", "id": 2, "competitor": 1, "params": "09:30:00.000"},
+		{"time": "09:31:49.285", "id": 5, "competitor": 1, "params": 1}
+	]`))
+	require.NoError(t, err)
+	assert.Equal(t, decodedEvents[:3], events)
+
+	_, err = utils.UnmarshalJSONEvents([]byte(`[{"id": 1, "competitor": 1}]`))
+	require.ErrorContains(t, err, "event 0: missing time")
+
+	_, err = utils.UnmarshalJSONEvents([]byte(`[1, 2]`))
+	require.Error(t, err)
+}
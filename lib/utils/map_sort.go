@@ -16,11 +16,11 @@ func Sort(m map[int]*Result) []int {
 	}
 
 	sort.Slice(ss, func(i, j int) bool {
-		iNotReady := ss[i].Value.Status == "[NotStarted]" || ss[i].Value.Status == "[NotFinished]"
-		jNotReady := ss[j].Value.Status == "[NotStarted]" || ss[j].Value.Status == "[NotFinished]"
+		iNotReady := notReady(ss[i].Value.Status)
+		jNotReady := notReady(ss[j].Value.Status)
 
 		if iNotReady && jNotReady {
-			return ss[i].Value.TotalTime < ss[j].Value.TotalTime
+			return less(ss[i], ss[j])
 		}
 
 		if iNotReady {
@@ -30,7 +30,7 @@ func Sort(m map[int]*Result) []int {
 			return false
 		}
 
-		return ss[i].Value.TotalTime < ss[j].Value.TotalTime
+		return less(ss[i], ss[j])
 	})
 
 	keys := make([]int, len(ss))
@@ -40,3 +40,14 @@ func Sort(m map[int]*Result) []int {
 
 	return keys
 }
+
+func notReady(status string) bool {
+	return status == StatusNotStarted || status == StatusNotFinished || status == StatusDisqualified
+}
+
+func less(a, b kv) bool {
+	if a.Value.TotalTime != b.Value.TotalTime {
+		return a.Value.TotalTime < b.Value.TotalTime
+	}
+	return a.Key < b.Key
+}
@@ -9,6 +9,14 @@ func ParseTime(timeStr string) (time.Time, error) {
 	return time.Parse("15:04:05.000", timeStr)
 }
 
+// parseRawTime parses an event time in the [HH:MM:SS.sss] form.
+func parseRawTime(rawTime string) (time.Time, error) {
+	if len(rawTime) < 2 || rawTime[0] != '[' || rawTime[len(rawTime)-1] != ']' {
+		return time.Time{}, fmt.Errorf("invalid event time %q", rawTime)
+	}
+	return ParseTime(rawTime[1 : len(rawTime)-1])
+}
+
 func ParseDuration(timeStr string, layout string) (time.Duration, error) {
 	t, err := time.Parse(layout, timeStr)
 	if err != nil {
@@ -30,3 +38,11 @@ func FormatDurationToTime(d time.Duration) string {
 	ms := d / time.Millisecond
 	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
 }
+
+// formatSpeed returns the average speed in m/s, or n/a for an empty interval.
+func formatSpeed(distance int, d time.Duration) string {
+	if d <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f", float64(distance)/d.Seconds())
+}
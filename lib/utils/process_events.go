@@ -13,215 +13,98 @@ type Competitor struct {
 	ID                   int
 	Registered           bool
 	PlannedStart         time.Time
+	HasStarted           bool
+	Legs                 int
+	LegTimes             []time.Duration
 	ActualStart          time.Time
+	LapStart             time.Time
 	PenaltyStart         time.Time
 	IsFinishedCompletely bool
 	IsDisqualified       bool
+	DisqualifiedByJury   bool
 	IsNotFinished        bool
 	FinishTime           time.Time
+	RaceTime             time.Duration
 	LapTimes             []time.Duration
 	PenaltyTimes         []time.Duration
+	PenaltyDistances     []int
 	ShootingResults      map[int][]bool
+	RangeVisits          []*RangeVisit
 	CurrentLap           int
 	OnFiringRange        bool
 	OnPenaltyLoop        bool
 	Comment              string
 }
 
-type Result struct {
-	CompetitorID  int
-	Status        string
-	Laps          int
-	LapTimes      []string
-	AvgSpeeds     []string
-	PenaltyTimes  []string
-	PenaltySpeeds []string
-	ShootingStats string
-	TotalTime     time.Duration
+func (c *Competitor) LastVisit() *RangeVisit {
+	if len(c.RangeVisits) == 0 {
+		return nil
+	}
+	return c.RangeVisits[len(c.RangeVisits)-1]
 }
 
-func ProcessEvents(cfg *configs.Config, events []Event) ([]string, map[int]*Result, []int) {
-	competitors := make(map[int]*Competitor)
-	results := make(map[int]*Result)
-	var outputEvents []string
-
-	startDelta, _ := ParseDuration(cfg.StartDelta, "15:04:05.000")
-
-	for _, event := range events {
-		eventTime, _ := ParseTime(event.RawTime[1 : len(event.RawTime)-1])
-		competitor, exists := competitors[event.CompetitorID]
-		if !exists {
-			competitor = &Competitor{
-				ID:              event.CompetitorID,
-				ShootingResults: make(map[int][]bool),
-			}
-			competitors[event.CompetitorID] = competitor
-		}
+// CurrentVisit returns the firing range visit in progress, if any.
+func (c *Competitor) CurrentVisit() *RangeVisit {
+	if !c.OnFiringRange || len(c.RangeVisits) == 0 {
+		return nil
+	}
+	return c.RangeVisits[len(c.RangeVisits)-1]
+}
 
-		if _, exists := results[event.CompetitorID]; !exists {
-			results[event.CompetitorID] = &Result{CompetitorID: competitor.ID, Laps: cfg.Laps}
-		}
+// Result statuses of competitors that have no finish time.
+const (
+	StatusNotStarted   = "[NotStarted]"
+	StatusNotFinished  = "[NotFinished]"
+	StatusDisqualified = "[Disqualified]" // by the jury
+)
 
-		switch event.ID {
-		case 1:
-			competitor.Registered = true
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) registered", event.RawTime, event.CompetitorID),
-			)
-		case 2:
-			plannedTime, _ := ParseTime(event.ExtraParams)
-			competitor.PlannedStart = plannedTime
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf(
-					"%s The start time for the competitor(%d) was set by a draw to %s",
-					event.RawTime,
-					event.CompetitorID,
-					event.ExtraParams,
-				),
-			)
-		case 3:
-			if eventTime.After(competitor.PlannedStart.Add(startDelta)) {
-				competitor.IsDisqualified = true
-				outputEvents = append(
-					outputEvents,
-					fmt.Sprintf("%s The competitor(%d) is disqualified", event.RawTime, event.CompetitorID),
-				)
-				break
-			}
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) is on the start line", event.RawTime, event.CompetitorID),
-			)
-		case 4:
-			competitor.ActualStart = eventTime
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) has started", event.RawTime, event.CompetitorID),
-			)
-		case 5:
-			competitor.OnFiringRange = true
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf(
-					"%s The competitor(%d) is on the firing range(%s)",
-					event.RawTime,
-					event.CompetitorID,
-					event.ExtraParams,
-				),
-			)
-		case 6:
-			if competitor.ShootingResults[competitor.CurrentLap] == nil {
-				competitor.ShootingResults[competitor.CurrentLap] = make([]bool, 0)
-			}
-			competitor.ShootingResults[competitor.CurrentLap] = append(
-				competitor.ShootingResults[competitor.CurrentLap],
-				true,
-			)
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf(
-					"%s The target(%s) has been hit by competitor(%d)",
-					event.RawTime,
-					event.ExtraParams,
-					event.CompetitorID,
-				),
-			)
-		case 7:
-			competitor.OnFiringRange = false
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) left the firing range", event.RawTime, event.CompetitorID),
-			)
-		case 8:
-			competitor.OnPenaltyLoop = true
-			competitor.PenaltyStart = eventTime
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) entered the penalty laps", event.RawTime, event.CompetitorID),
-			)
-		case 9:
-			competitor.OnPenaltyLoop = false
-			penaltyTime := eventTime.Sub(competitor.PenaltyStart)
-			competitor.PenaltyTimes = append(competitor.PenaltyTimes, penaltyTime)
-			results[event.CompetitorID].PenaltyTimes = append(
-				results[event.CompetitorID].PenaltyTimes,
-				FormatDurationToTime(penaltyTime),
-			)
-			speed := float64(cfg.PenaltyLength) / penaltyTime.Seconds()
-			results[event.CompetitorID].PenaltySpeeds = append(
-				results[event.CompetitorID].PenaltySpeeds,
-				fmt.Sprintf("%.3f", speed),
-			)
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) left the penalty laps", event.RawTime, event.CompetitorID),
-			)
-		case 10:
-			lapTime := eventTime.Sub(competitor.ActualStart)
-			competitor.LapTimes = append(competitor.LapTimes, lapTime)
-			results[event.CompetitorID].TotalTime = eventTime.Sub(competitor.ActualStart)
-			results[event.CompetitorID].LapTimes = append(
-				results[event.CompetitorID].LapTimes,
-				FormatDurationToTime(lapTime),
-			)
-			speed := float64(cfg.LapLength) / lapTime.Seconds()
-			results[event.CompetitorID].AvgSpeeds = append(
-				results[event.CompetitorID].AvgSpeeds,
-				fmt.Sprintf("%.3f", speed),
-			)
-			competitor.CurrentLap++
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf("%s The competitor(%d) ended the main lap", event.RawTime, event.CompetitorID),
-			)
-			if competitor.CurrentLap >= cfg.Laps {
-				competitor.IsFinishedCompletely = true
-				competitor.FinishTime = eventTime
-				outputEvents = append(
-					outputEvents,
-					fmt.Sprintf("%s The competitor(%d) has finished", event.RawTime, event.CompetitorID),
-				)
-			}
-		case 11:
-			competitor.IsNotFinished = true
-			competitor.Comment = event.ExtraParams
-			outputEvents = append(
-				outputEvents,
-				fmt.Sprintf(
-					"%s The competitor(%d) can`t continue: %s",
-					event.RawTime,
-					event.CompetitorID,
-					event.ExtraParams,
-				),
-			)
-		}
-	}
+type Result struct {
+	CompetitorID     int
+	Status           string
+	Laps             int
+	LapTimes         []string
+	Splits           []string
+	AvgSpeeds        []string
+	PenaltyTimes     []string
+	PenaltySpeeds    []string
+	PenaltyTotalTime string
+	PenaltyAvgSpeed  string
+	ShootingStats    string
+	VisitStats       []string
+	ProneStats       string
+	StandingStats    string
+	SkippedLoops     int
+	TotalTime        time.Duration
+
+	// The values behind the formatted fields above, for reporters that keep types.
+	FinishTime       time.Time
+	LapDurations     []time.Duration
+	SplitDurations   []time.Duration
+	LapLengths       []int
+	PenaltyDurations []time.Duration
+	PenaltyDistances []int
+	Hits             int
+	Shots            int
+	Visits           []*RangeVisit
+}
 
-	for _, competitor := range competitors {
-		result := results[competitor.ID]
+// Finished reports whether the competitor has completed every lap.
+func (r *Result) Finished() bool {
+	return !r.FinishTime.IsZero()
+}
 
-		switch {
-		case competitor.IsDisqualified:
-			result.Status = "[NotStarted]"
-		case competitor.IsNotFinished:
-			result.Status = "[NotFinished]"
-		default:
-			result.Status = competitor.FinishTime.Format("15:04:05.000")
-		}
+func ProcessEvents(cfg *configs.Config, events []Event) ([]string, map[int]*Result, []int) {
+	processor := NewProcessor(cfg)
 
-		hits := 0
-		shots := len(competitor.ShootingResults) * 5
-		for _, lapHits := range competitor.ShootingResults {
-			hits += len(lapHits)
-		}
-		result.ShootingStats = fmt.Sprintf("%d/%d", hits, shots)
+	for _, event := range events {
+		processor.Apply(event)
 	}
+	processor.Close()
 
+	results := processor.Results()
 	order := Sort(results)
 
-	return outputEvents, results, order
+	return processor.Output(), results, order
 }
 
 func FormatResult(result *Result) string {
@@ -266,5 +149,26 @@ func FormatResult(result *Result) string {
 
 	builder.WriteString(result.ShootingStats)
 
+	if len(result.VisitStats) > 0 {
+		builder.WriteString(" [")
+		builder.WriteString(strings.Join(result.VisitStats, ", "))
+		builder.WriteString("]")
+	}
+
+	var positions []string
+	if result.ProneStats != "" {
+		positions = append(positions, "prone "+result.ProneStats)
+	}
+	if result.StandingStats != "" {
+		positions = append(positions, "standing "+result.StandingStats)
+	}
+	if len(positions) > 0 {
+		builder.WriteString(" (" + strings.Join(positions, ", ") + ")")
+	}
+
+	if result.SkippedLoops > 0 {
+		builder.WriteString(fmt.Sprintf(" (skipped loops: %d)", result.SkippedLoops))
+	}
+
 	return builder.String()
 }
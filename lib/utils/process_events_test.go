@@ -2,6 +2,7 @@ package utils_test
 
 import (
 	"testing"
+	"time"
 
 	"biathlon-competitions-prototype/configs"
 	"biathlon-competitions-prototype/lib/utils"
@@ -39,7 +40,7 @@ func TestResultInitialization(t *testing.T) {
 
 func TestProcessEvents(t *testing.T) {
 	cfg := &configs.Config{
-		StartDelta:    "00:00:30.000",
+		StartDelta:    configs.Duration(30 * time.Second),
 		Laps:          2,
 		LapLength:     4000,
 		PenaltyLength: 150,
@@ -270,6 +271,34 @@ func TestFormatResult(t *testing.T) {
 			},
 			expected: "[NotFinished] 3 [{03:45.123, 15.123}, {,}] [] 5/5",
 		},
+		{
+			name: "With range visits",
+			result: &utils.Result{
+				CompetitorID:  4,
+				Status:        "10:30:15.500",
+				Laps:          1,
+				LapTimes:      []string{"03:45.123"},
+				AvgSpeeds:     []string{"15.123"},
+				ShootingStats: "9/10",
+				VisitStats:    []string{"4/5", "5/5"},
+			},
+			expected: "[10:30:15.500] 4 [{03:45.123, 15.123}] [] 9/10 [4/5, 5/5]",
+		},
+		{
+			name: "With shooting positions",
+			result: &utils.Result{
+				CompetitorID:  5,
+				Status:        "10:30:15.500",
+				Laps:          1,
+				LapTimes:      []string{"03:45.123"},
+				AvgSpeeds:     []string{"15.123"},
+				ShootingStats: "9/10",
+				VisitStats:    []string{"4/5", "5/5"},
+				ProneStats:    "4/5",
+				StandingStats: "5/5",
+			},
+			expected: "[10:30:15.500] 5 [{03:45.123, 15.123}] [] 9/10 [4/5, 5/5] (prone 4/5, standing 5/5)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -284,7 +313,7 @@ func TestFormatResult(t *testing.T) {
 
 func TestShootingStatsCalculation(t *testing.T) {
 	cfg := &configs.Config{
-		StartDelta:    "00:00:30.000",
+		StartDelta:    configs.Duration(30 * time.Second),
 		Laps:          2,
 		LapLength:     4000,
 		PenaltyLength: 150,
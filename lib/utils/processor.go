@@ -0,0 +1,461 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+)
+
+// Processor keeps the race state and applies incoming events one at a time,
+// so it can be fed from a live timing source as well as from a complete file.
+type Processor struct {
+	cfg                *configs.Config
+	start              time.Time
+	startDelta         time.Duration
+	skippedLoopPenalty time.Duration
+	teams              map[int]*configs.Team
+	legs               map[int]int
+	competitors        map[int]*Competitor
+	results            map[int]*Result
+	events             []Event
+	outputEvents       []string
+	// loopTimes is the time per loop of every penalty block after a visit
+	// with misses, the reference for the loops skied without penaltySpeed.
+	loopTimes []time.Duration
+}
+
+func NewProcessor(cfg *configs.Config) *Processor {
+	teams := make(map[int]*configs.Team)
+	legs := make(map[int]int)
+	if cfg.Format.IsRelay() {
+		for i := range cfg.Relay.Teams {
+			for _, id := range cfg.Relay.Teams[i].Legs {
+				teams[id] = &cfg.Relay.Teams[i]
+				legs[id]++
+			}
+		}
+	}
+
+	return &Processor{
+		cfg:                cfg,
+		teams:              teams,
+		legs:               legs,
+		start:              cfg.Start.Time(),
+		startDelta:         cfg.StartDelta.Duration(),
+		skippedLoopPenalty: cfg.SkippedLoopPenalty.Duration(),
+		competitors:        make(map[int]*Competitor),
+		results:            make(map[int]*Result),
+	}
+}
+
+// Apply processes a single event and returns the output log lines it produced.
+func (p *Processor) Apply(event Event) []string {
+	if reason := p.rejectRelayEvent(event); reason != "" {
+		line := fmt.Sprintf("%s The event(%d) of the competitor(%d) is ignored: %s",
+			event.RawTime, event.ID, event.CompetitorID, reason)
+		p.outputEvents = append(p.outputEvents, line)
+		return []string{line}
+	}
+
+	eventTime, _ := parseRawTime(event.RawTime)
+	competitor := p.competitor(event.CompetitorID)
+	result := p.results[event.CompetitorID]
+	var outgoing []Event
+
+	// Event 3 applies its own lateness rule to the competitor it belongs to.
+	var except *Competitor
+	if event.ID == 3 {
+		except = competitor
+	}
+	lines := p.expireStarts(eventTime, except)
+
+	switch event.ID {
+	case 1:
+		competitor.Registered = true
+		if competitor.PlannedStart.IsZero() && p.startsWithRace(competitor.ID) {
+			competitor.PlannedStart = p.start
+		}
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) registered", event.RawTime, event.CompetitorID))
+	case 2:
+		plannedTime, _ := ParseTime(event.ExtraParams)
+		competitor.PlannedStart = plannedTime
+		competitor.HasStarted = false
+		lines = append(
+			lines,
+			fmt.Sprintf(
+				"%s The start time for the competitor(%d) was set by a draw to %s",
+				event.RawTime,
+				event.CompetitorID,
+				event.ExtraParams,
+			),
+		)
+	case 3:
+		if eventTime.After(competitor.PlannedStart.Add(p.startDelta)) {
+			if competitor.IsDisqualified {
+				break
+			}
+			competitor.IsDisqualified = true
+			outgoing = append(outgoing, newOutgoingEvent(EventDisqualified, event))
+			lines = append(lines, fmt.Sprintf("%s The competitor(%d) is disqualified", event.RawTime, event.CompetitorID))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) is on the start line", event.RawTime, event.CompetitorID))
+	case 4:
+		p.startLeg(competitor, eventTime)
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) has started", event.RawTime, event.CompetitorID))
+	case 5:
+		competitor.OnFiringRange = true
+		firingRange, _ := strconv.Atoi(event.ExtraParams)
+		visit := newRangeVisit(p.cfg, firingRange, competitor.CurrentLap)
+		competitor.RangeVisits = append(competitor.RangeVisits, visit)
+		lines = append(
+			lines,
+			fmt.Sprintf(
+				"%s The competitor(%d) is on the firing range(%s)",
+				event.RawTime,
+				event.CompetitorID,
+				event.ExtraParams,
+			),
+		)
+	case 6:
+		if competitor.ShootingResults[competitor.CurrentLap] == nil {
+			competitor.ShootingResults[competitor.CurrentLap] = make([]bool, 0)
+		}
+		competitor.ShootingResults[competitor.CurrentLap] = append(
+			competitor.ShootingResults[competitor.CurrentLap],
+			true,
+		)
+		if visit := competitor.CurrentVisit(); visit != nil {
+			visit.Hit(parseTarget(event.ExtraParams))
+		}
+		lines = append(
+			lines,
+			fmt.Sprintf(
+				"%s The target(%s) has been hit by competitor(%d)",
+				event.RawTime,
+				event.ExtraParams,
+				event.CompetitorID,
+			),
+		)
+	case 7:
+		competitor.OnFiringRange = false
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) left the firing range", event.RawTime, event.CompetitorID))
+	case 8:
+		competitor.OnPenaltyLoop = true
+		competitor.PenaltyStart = eventTime
+		lines = append(
+			lines,
+			fmt.Sprintf("%s The competitor(%d) entered the penalty laps", event.RawTime, event.CompetitorID),
+		)
+	case 9:
+		competitor.OnPenaltyLoop = false
+		penaltyTime := eventTime.Sub(competitor.PenaltyStart)
+		distance := requiredPenaltyLoops(competitor.LastVisit()) * p.cfg.PenaltyLength
+		competitor.PenaltyTimes = append(competitor.PenaltyTimes, penaltyTime)
+		competitor.PenaltyDistances = append(competitor.PenaltyDistances, distance)
+		result.PenaltyTimes = append(result.PenaltyTimes, FormatDurationToTime(penaltyTime))
+		result.PenaltySpeeds = append(result.PenaltySpeeds, formatSpeed(distance, penaltyTime))
+		result.PenaltyDurations = append(result.PenaltyDurations, penaltyTime)
+		result.PenaltyDistances = append(result.PenaltyDistances, distance)
+		if visit := competitor.LastVisit(); visit != nil && !visit.Closed {
+			visit.PenaltyTime = penaltyTime
+			visit.Closed = true
+			if visit.Misses() > 0 {
+				p.loopTimes = append(p.loopTimes, penaltyTime/time.Duration(visit.Misses()))
+				p.reestimate(competitor)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) left the penalty laps", event.RawTime, event.CompetitorID))
+	case 10:
+		lapTime := eventTime.Sub(competitor.LapStart)
+		competitor.LapStart = eventTime
+		competitor.LapTimes = append(competitor.LapTimes, lapTime)
+		competitor.RaceTime = eventTime.Sub(p.raceStart(competitor))
+		result.LapTimes = append(result.LapTimes, FormatDurationToTime(lapTime))
+		split := eventTime.Sub(competitor.ActualStart)
+		result.Splits = append(result.Splits, FormatDurationToTime(split))
+		result.SplitDurations = append(result.SplitDurations, split)
+		for _, visit := range competitor.RangeVisits {
+			visit.Closed = true
+		}
+		result.AvgSpeeds = append(result.AvgSpeeds, formatSpeed(p.lapLength(competitor), lapTime))
+		result.LapDurations = append(result.LapDurations, lapTime)
+		result.LapLengths = append(result.LapLengths, p.lapLength(competitor))
+		competitor.CurrentLap++
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) ended the main lap", event.RawTime, event.CompetitorID))
+		if competitor.CurrentLap >= p.lapsFor(competitor) {
+			competitor.IsFinishedCompletely = true
+			competitor.FinishTime = eventTime
+			competitor.LegTimes = append(competitor.LegTimes, eventTime.Sub(competitor.ActualStart))
+			outgoing = append(outgoing, newOutgoingEvent(EventFinished, event))
+			lines = append(lines, fmt.Sprintf("%s The competitor(%d) has finished", event.RawTime, event.CompetitorID))
+		}
+	case EventHandOver:
+		lines = append(lines, p.handOver(competitor, event, eventTime))
+	case EventSpareRound:
+		if visit := competitor.CurrentVisit(); visit != nil {
+			visit.SpareRounds++
+		}
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) loaded a spare round", event.RawTime, event.CompetitorID))
+	case EventJuryDisqualification:
+		if competitor.IsDisqualified {
+			break
+		}
+		competitor.IsDisqualified = true
+		competitor.DisqualifiedByJury = true
+		competitor.Comment = event.ExtraParams
+		outgoing = append(outgoing, newOutgoingEvent(EventDisqualified, event))
+		lines = append(
+			lines,
+			fmt.Sprintf(
+				"%s The competitor(%d) is disqualified by the jury: %s",
+				event.RawTime,
+				event.CompetitorID,
+				event.ExtraParams,
+			),
+		)
+	case 11:
+		competitor.IsNotFinished = true
+		competitor.Comment = event.ExtraParams
+		lines = append(
+			lines,
+			fmt.Sprintf(
+				"%s The competitor(%d) can`t continue: %s",
+				event.RawTime,
+				event.CompetitorID,
+				event.ExtraParams,
+			),
+		)
+	}
+
+	p.summarize(competitor)
+	p.events = append(p.events, event)
+	p.events = append(p.events, outgoing...)
+	p.outputEvents = append(p.outputEvents, lines...)
+
+	return lines
+}
+
+// Advance disqualifies every competitor whose start window closed before now
+// without a start, and returns the output log lines produced.
+func (p *Processor) Advance(now time.Time) []string {
+	lines := p.expireStarts(now, nil)
+	p.outputEvents = append(p.outputEvents, lines...)
+
+	return lines
+}
+
+// Close marks the end of input: competitors that drew a start time but never
+// started are disqualified at the end of their start window.
+func (p *Processor) Close() []string {
+	var latest time.Time
+	for _, competitor := range p.competitors {
+		if deadline := competitor.PlannedStart.Add(p.startDelta); deadline.After(latest) {
+			latest = deadline
+		}
+	}
+
+	return p.Advance(latest.Add(time.Millisecond))
+}
+
+// Output returns every output log line produced so far.
+func (p *Processor) Output() []string {
+	return p.outputEvents
+}
+
+// Events returns every incoming event applied so far together with the outgoing
+// events generated for them, in the order they occurred.
+func (p *Processor) Events() []Event {
+	return p.events
+}
+
+// Outgoing returns the outgoing events generated so far, in the order they
+// occurred.
+func (p *Processor) Outgoing() []Event {
+	var outgoing []Event
+	for _, event := range p.events {
+		if event.IsOutgoing() {
+			outgoing = append(outgoing, event)
+		}
+	}
+	return outgoing
+}
+
+func (p *Processor) Competitor(id int) (*Competitor, bool) {
+	competitor, ok := p.competitors[id]
+	return competitor, ok
+}
+
+func (p *Processor) Result(id int) (*Result, bool) {
+	result, ok := p.results[id]
+	return result, ok
+}
+
+func (p *Processor) Results() map[int]*Result {
+	return p.results
+}
+
+// Standings returns the current results ranked the same way as the final report.
+func (p *Processor) Standings() []*Result {
+	order := Sort(p.results)
+
+	standings := make([]*Result, len(order))
+	for i, id := range order {
+		standings[i] = p.results[id]
+	}
+
+	return standings
+}
+
+// lapLength returns the length of the lap the competitor is on. Relay athletes
+// ski the same course on every leg.
+func (p *Processor) lapLength(competitor *Competitor) int {
+	return p.cfg.LapLengthOf(competitor.CurrentLap % max(1, p.cfg.Laps))
+}
+
+func (p *Processor) competitor(id int) *Competitor {
+	competitor, exists := p.competitors[id]
+	if !exists {
+		competitor = &Competitor{
+			ID:              id,
+			ShootingResults: make(map[int][]bool),
+		}
+		p.competitors[id] = competitor
+	}
+
+	if _, exists := p.results[id]; !exists {
+		p.results[id] = &Result{CompetitorID: id, Laps: p.cfg.Laps * max(1, p.legs[id])}
+	}
+
+	return competitor
+}
+
+func (p *Processor) expireStarts(now time.Time, except *Competitor) []string {
+	var expired []*Competitor
+	for _, competitor := range p.competitors {
+		if competitor == except || competitor.IsDisqualified || competitor.HasStarted || competitor.PlannedStart.IsZero() {
+			continue
+		}
+		if now.After(competitor.PlannedStart.Add(p.startDelta)) {
+			expired = append(expired, competitor)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		if !expired[i].PlannedStart.Equal(expired[j].PlannedStart) {
+			return expired[i].PlannedStart.Before(expired[j].PlannedStart)
+		}
+		return expired[i].ID < expired[j].ID
+	})
+
+	lines := make([]string, 0, len(expired))
+	for _, competitor := range expired {
+		rawTime := "[" + competitor.PlannedStart.Add(p.startDelta).Format("15:04:05.000") + "]"
+		competitor.IsDisqualified = true
+		p.events = append(p.events, Event{ID: EventDisqualified, RawTime: rawTime, CompetitorID: competitor.ID})
+		lines = append(lines, fmt.Sprintf("%s The competitor(%d) is disqualified", rawTime, competitor.ID))
+		p.summarize(competitor)
+	}
+
+	return lines
+}
+
+func newOutgoingEvent(id int, cause Event) Event {
+	return Event{
+		ID:           id,
+		RawTime:      cause.RawTime,
+		CompetitorID: cause.CompetitorID,
+	}
+}
+
+func (p *Processor) summarize(competitor *Competitor) {
+	result := p.results[competitor.ID]
+
+	result.FinishTime = time.Time{}
+	switch {
+	case competitor.DisqualifiedByJury:
+		result.Status = StatusDisqualified
+	case competitor.IsDisqualified:
+		result.Status = StatusNotStarted
+	case competitor.IsNotFinished:
+		result.Status = StatusNotFinished
+	default:
+		result.Status = competitor.FinishTime.Format("15:04:05.000")
+		if competitor.IsFinishedCompletely {
+			result.FinishTime = competitor.FinishTime
+		}
+	}
+
+	hits, shots := 0, 0
+	positionHits := make(map[Position]int)
+	positionShots := make(map[Position]int)
+	result.VisitStats = make([]string, len(competitor.RangeVisits))
+	for i, visit := range competitor.RangeVisits {
+		hits += visit.Hits()
+		shots += visit.Shots()
+		positionHits[visit.Position] += visit.Hits()
+		positionShots[visit.Position] += visit.Shots()
+		result.VisitStats[i] = visit.String()
+	}
+	result.ShootingStats = fmt.Sprintf("%d/%d", hits, shots)
+	result.Hits, result.Shots = hits, shots
+	result.Visits = competitor.RangeVisits
+
+	result.ProneStats, result.StandingStats = "", ""
+	if positionShots[PositionProne] > 0 {
+		result.ProneStats = fmt.Sprintf("%d/%d", positionHits[PositionProne], positionShots[PositionProne])
+	}
+	if positionShots[PositionStanding] > 0 {
+		result.StandingStats = fmt.Sprintf("%d/%d", positionHits[PositionStanding], positionShots[PositionStanding])
+	}
+
+	var penaltyDistance int
+	var penaltyTime time.Duration
+	for i, distance := range competitor.PenaltyDistances {
+		penaltyDistance += distance
+		penaltyTime += competitor.PenaltyTimes[i]
+	}
+	if len(competitor.PenaltyTimes) > 0 {
+		result.PenaltyTotalTime = FormatDurationToTime(penaltyTime)
+		result.PenaltyAvgSpeed = formatSpeed(penaltyDistance, penaltyTime)
+	}
+
+	result.SkippedLoops = 0
+	if p.cfg.Format != configs.FormatIndividual {
+		reference := p.referenceLoopTime()
+		for _, visit := range competitor.RangeVisits {
+			visit.PenaltyLoops = estimatePenaltyLoops(visit, reference)
+			result.SkippedLoops += visit.SkippedLoops()
+		}
+	}
+	result.TotalTime = competitor.RaceTime + p.timePenalty(competitor, result.SkippedLoops)
+}
+
+// raceStart returns the moment the race time of the competitor is counted from.
+// Pursuit and mass start are ranked first across the line, so their time runs
+// from the start of the race rather than the individual start.
+func (p *Processor) raceStart(competitor *Competitor) time.Time {
+	if p.cfg.Format == configs.FormatPursuit || p.cfg.Format == configs.FormatMassStart {
+		return p.start
+	}
+	return competitor.ActualStart
+}
+
+// timePenalty returns the time added to the race time for shooting. Individual
+// races add a penalty minute per miss, other formats penalise skipped loops.
+func (p *Processor) timePenalty(competitor *Competitor, skippedLoops int) time.Duration {
+	if p.cfg.Format == configs.FormatIndividual {
+		misses := 0
+		for _, visit := range competitor.RangeVisits {
+			if visit != competitor.CurrentVisit() {
+				misses += visit.Misses()
+			}
+		}
+		return time.Duration(misses) * individualPenalty
+	}
+
+	return time.Duration(skippedLoops) * p.skippedLoopPenalty
+}
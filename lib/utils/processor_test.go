@@ -0,0 +1,616 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func TestProcessorApply(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	processor := utils.NewProcessor(cfg)
+
+	lines := processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1})
+	assert.Equal(t, []string{"[10:00:00.000] The competitor(1) registered"}, lines)
+
+	competitor, ok := processor.Competitor(1)
+	require.True(t, ok)
+	assert.True(t, competitor.Registered)
+
+	_, ok = processor.Competitor(2)
+	assert.False(t, ok)
+
+	processor.Apply(utils.Event{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"})
+	processor.Apply(utils.Event{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4})
+
+	lines = processor.Apply(utils.Event{RawTime: "[10:05:30.000]", CompetitorID: 1, ID: 10})
+	assert.Equal(t, []string{
+		"[10:05:30.000] The competitor(1) ended the main lap",
+		"[10:05:30.000] The competitor(1) has finished",
+	}, lines)
+
+	assert.Len(t, processor.Output(), 5)
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, "10:05:30.000", result.Status)
+}
+
+func TestProcessorStandings(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:00.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:01:00.000]", CompetitorID: 2, ID: 4},
+		{RawTime: "[10:05:00.000]", CompetitorID: 2, ID: 10},
+		{RawTime: "[10:06:00.000]", CompetitorID: 1, ID: 10},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	standings := processor.Standings()
+	require.Len(t, standings, 2)
+	assert.Equal(t, 2, standings[0].CompetitorID)
+	assert.Equal(t, 1, standings[1].CompetitorID)
+}
+
+func TestProcessorLapSplits(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          2,
+		LapLength:     3000,
+		PenaltyLength: 150,
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:10:30.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:22:30.000]", CompetitorID: 1, ID: 10},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, []string{"00:10:00.000", "00:12:00.000"}, result.LapTimes)
+	assert.Equal(t, []string{"00:10:00.000", "00:22:00.000"}, result.Splits)
+	assert.Equal(t, []time.Duration{10 * time.Minute, 22 * time.Minute}, result.SplitDurations)
+	assert.Equal(t, []string{"5.000", "4.167"}, result.AvgSpeeds)
+}
+
+func TestProcessorOutgoingEvents(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:00.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:01:31.000]", CompetitorID: 2, ID: 3},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 10},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	assert.Equal(t, []utils.Event{
+		{RawTime: "[10:01:31.000]", CompetitorID: 2, ID: utils.EventDisqualified},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: utils.EventFinished},
+	}, processor.Outgoing())
+	assert.Len(t, processor.Events(), len(events)+2)
+}
+
+func TestProcessorStartWindowExpiry(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	processor := utils.NewProcessor(cfg)
+	processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"})
+	processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:00.000"})
+	processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 3, ID: 2, ExtraParams: "10:01:30.000"})
+	processor.Apply(utils.Event{RawTime: "[10:01:00.000]", CompetitorID: 2, ID: 4})
+
+	lines := processor.Apply(utils.Event{RawTime: "[10:01:10.000]", CompetitorID: 2, ID: 5, ExtraParams: "1"})
+	assert.Equal(t, []string{
+		"[10:01:00.000] The competitor(1) is disqualified",
+		"[10:01:10.000] The competitor(2) is on the firing range(1)",
+	}, lines)
+
+	lines = processor.Close()
+	assert.Equal(t, []string{"[10:02:00.000] The competitor(3) is disqualified"}, lines)
+
+	for _, id := range []int{1, 3} {
+		result, ok := processor.Result(id)
+		require.True(t, ok)
+		assert.Equal(t, "[NotStarted]", result.Status)
+	}
+
+	result, ok := processor.Result(2)
+	require.True(t, ok)
+	assert.NotEqual(t, "[NotStarted]", result.Status)
+}
+
+func TestProcessorJuryDisqualification(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	processor := utils.NewProcessor(cfg)
+	processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"})
+	processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:00.000"})
+	processor.Apply(utils.Event{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4})
+	processor.Apply(utils.Event{RawTime: "[10:01:00.000]", CompetitorID: 2, ID: 4})
+
+	lines := processor.Apply(utils.Event{
+		RawTime: "[10:03:00.000]", CompetitorID: 1, ID: utils.EventJuryDisqualification, ExtraParams: "Shortcut",
+	})
+	assert.Equal(t, []string{"[10:03:00.000] The competitor(1) is disqualified by the jury: Shortcut"}, lines)
+	lines = processor.Apply(utils.Event{
+		RawTime: "[10:03:10.000]", CompetitorID: 1, ID: utils.EventJuryDisqualification, ExtraParams: "Shortcut",
+	})
+	assert.Empty(t, lines, "a competitor is disqualified once")
+
+	processor.Apply(utils.Event{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 10})
+	processor.Apply(utils.Event{RawTime: "[10:06:00.000]", CompetitorID: 2, ID: 10})
+
+	assert.Equal(t, []utils.Event{
+		{RawTime: "[10:03:00.000]", CompetitorID: 1, ID: utils.EventDisqualified},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: utils.EventFinished},
+		{RawTime: "[10:06:00.000]", CompetitorID: 2, ID: utils.EventFinished},
+	}, processor.Outgoing())
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, utils.StatusDisqualified, result.Status)
+	assert.False(t, result.Finished(), "crossing the line does not undo the decision")
+
+	standings := processor.Standings()
+	require.Len(t, standings, 2)
+	assert.Equal(t, 1, standings[0].CompetitorID, "the report lists disqualified competitors with the other unranked ones")
+}
+
+func TestProcessorRangeVisits(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          2,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:02:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:02:33.000]", CompetitorID: 1, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:03:30.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:05:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "2"},
+		{RawTime: "[10:05:40.000]", CompetitorID: 1, ID: 7},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	competitor, ok := processor.Competitor(1)
+	require.True(t, ok)
+	require.Len(t, competitor.RangeVisits, 2)
+
+	first := competitor.RangeVisits[0]
+	assert.Equal(t, 1, first.Range)
+	assert.Equal(t, 0, first.Lap)
+	assert.Equal(t, []int{3}, first.DuplicateHits)
+	assert.Equal(t, 3, first.Misses())
+
+	second := competitor.RangeVisits[1]
+	assert.Equal(t, 2, second.Range)
+	assert.Equal(t, 1, second.Lap)
+	assert.Equal(t, 5, second.Misses())
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, []string{"2/5", "0/5"}, result.VisitStats)
+}
+
+func TestProcessorShootingTotals(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          2,
+		LapLength:     4000,
+		PenaltyLength: 150,
+		FiringLines:   2,
+		ShotsPerLine:  4,
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:03:30.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:05:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "2"},
+		{RawTime: "[10:05:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "4"},
+		{RawTime: "[10:05:40.000]", CompetitorID: 1, ID: 7},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, "1/8", result.ShootingStats)
+	assert.Equal(t, []string{"0/4", "1/4"}, result.VisitStats)
+}
+
+func TestProcessorSkippedPenaltyLoops(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:         configs.Duration(30 * time.Second),
+		Laps:               1,
+		LapLength:          4000,
+		PenaltyLength:      150,
+		FiringLines:        2,
+		PenaltySpeed:       5,
+		SkippedLoopPenalty: configs.Duration(time.Minute),
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:02:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:02:45.000]", CompetitorID: 1, ID: 8},
+		{RawTime: "[10:03:45.000]", CompetitorID: 1, ID: 9},
+		{RawTime: "[10:05:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "2"},
+		{RawTime: "[10:05:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:05:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:05:33.000]", CompetitorID: 1, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:05:34.000]", CompetitorID: 1, ID: 6, ExtraParams: "4"},
+		{RawTime: "[10:05:40.000]", CompetitorID: 1, ID: 7},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	competitor, ok := processor.Competitor(1)
+	require.True(t, ok)
+	assert.Equal(t, 2, competitor.RangeVisits[0].PenaltyLoops)
+	assert.Equal(t, 1, competitor.RangeVisits[0].SkippedLoops())
+	assert.Equal(t, 0, competitor.RangeVisits[1].SkippedLoops())
+
+	processor.Apply(utils.Event{RawTime: "[10:06:30.000]", CompetitorID: 1, ID: 10})
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, 2, result.SkippedLoops)
+	assert.Equal(t, 8*time.Minute, result.TotalTime)
+	assert.Contains(t, utils.FormatResult(result), "(skipped loops: 2)")
+}
+
+func TestProcessorSkippedLoopAtSamplePace(t *testing.T) {
+	// One 150 m loop takes 50 s in the sample events. Competitor 3 misses two
+	// targets like the others but skis a single loop.
+	penalty := func(id int, enter, leave string) []utils.Event {
+		return []utils.Event{
+			{RawTime: "[10:00:00.000]", CompetitorID: id, ID: 1},
+			{RawTime: "[10:00:00.000]", CompetitorID: id, ID: 2, ExtraParams: "10:00:00.000"},
+			{RawTime: "[10:00:00.000]", CompetitorID: id, ID: 4},
+			{RawTime: "[10:08:49.289]", CompetitorID: id, ID: 5, ExtraParams: "1"},
+			{RawTime: "[10:08:50.884]", CompetitorID: id, ID: 6, ExtraParams: "1"},
+			{RawTime: "[10:08:51.400]", CompetitorID: id, ID: 6, ExtraParams: "2"},
+			{RawTime: "[10:08:52.797]", CompetitorID: id, ID: 6, ExtraParams: "5"},
+			{RawTime: "[10:08:55.658]", CompetitorID: id, ID: 7},
+			{RawTime: enter, CompetitorID: id, ID: 8},
+			{RawTime: leave, CompetitorID: id, ID: 9},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		penaltySpeed float64
+		competitors  [][]utils.Event
+	}{
+		{
+			name: "median of the race",
+			competitors: [][]utils.Event{
+				penalty(1, "[10:09:03.232]", "[10:10:43.232]"),
+				penalty(2, "[10:09:03.232]", "[10:10:43.232]"),
+				penalty(3, "[10:09:03.232]", "[10:09:53.232]"),
+			},
+		},
+		{
+			name:         "configured speed",
+			penaltySpeed: 3,
+			competitors:  [][]utils.Event{penalty(3, "[10:09:03.232]", "[10:09:53.232]")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configs.Config{
+				StartDelta:    configs.Duration(90 * time.Second),
+				Laps:          2,
+				LapLength:     3500,
+				PenaltyLength: 150,
+				FiringLines:   2,
+				PenaltySpeed:  tt.penaltySpeed,
+			}
+
+			processor := utils.NewProcessor(cfg)
+			for _, events := range tt.competitors {
+				for _, event := range events {
+					processor.Apply(event)
+				}
+			}
+
+			for id := 1; id < len(tt.competitors); id++ {
+				result, ok := processor.Result(id)
+				require.True(t, ok)
+				assert.Zero(t, result.SkippedLoops, "competitor %d skied every loop", id)
+			}
+
+			competitor, ok := processor.Competitor(3)
+			require.True(t, ok)
+			assert.Equal(t, 1, competitor.RangeVisits[0].PenaltyLoops)
+			result, ok := processor.Result(3)
+			require.True(t, ok)
+			assert.Equal(t, 1, result.SkippedLoops)
+		})
+	}
+}
+
+func TestProcessorPenaltySpeeds(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          2,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:02:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:02:45.000]", CompetitorID: 1, ID: 8},
+		{RawTime: "[10:04:15.000]", CompetitorID: 1, ID: 9},
+		{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:07:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "2"},
+		{RawTime: "[10:07:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:07:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:07:33.000]", CompetitorID: 1, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:07:34.000]", CompetitorID: 1, ID: 6, ExtraParams: "4"},
+		{RawTime: "[10:07:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:07:45.000]", CompetitorID: 1, ID: 8},
+		{RawTime: "[10:07:45.000]", CompetitorID: 1, ID: 9},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, []string{"00:01:30.000", "00:00:00.000"}, result.PenaltyTimes)
+	assert.Equal(t, []string{"5.000", "n/a"}, result.PenaltySpeeds)
+	assert.Equal(t, "00:01:30.000", result.PenaltyTotalTime)
+	assert.Equal(t, "6.667", result.PenaltyAvgSpeed)
+}
+
+func TestProcessorRaceFormats(t *testing.T) {
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 2, ID: 1},
+		{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:01:00.000"},
+		{RawTime: "[10:00:01.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:30.000"},
+		{RawTime: "[10:01:00.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:01:30.000]", CompetitorID: 2, ID: 4},
+		{RawTime: "[10:03:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:03:10.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:03:30.000]", CompetitorID: 2, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:03:31.000]", CompetitorID: 2, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:03:32.000]", CompetitorID: 2, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:03:33.000]", CompetitorID: 2, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:03:34.000]", CompetitorID: 2, ID: 6, ExtraParams: "4"},
+		{RawTime: "[10:03:35.000]", CompetitorID: 2, ID: 6, ExtraParams: "5"},
+		{RawTime: "[10:03:40.000]", CompetitorID: 2, ID: 7},
+		{RawTime: "[10:06:00.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:06:10.000]", CompetitorID: 2, ID: 10},
+	}
+
+	tests := []struct {
+		name     string
+		format   configs.Format
+		expected map[int]time.Duration
+		order    []int
+	}{
+		{
+			name:     "sprint",
+			format:   configs.FormatSprint,
+			expected: map[int]time.Duration{1: 5 * time.Minute, 2: 4*time.Minute + 40*time.Second},
+			order:    []int{2, 1},
+		},
+		{
+			name:     "individual",
+			format:   configs.FormatIndividual,
+			expected: map[int]time.Duration{1: 10 * time.Minute, 2: 4*time.Minute + 40*time.Second},
+			order:    []int{2, 1},
+		},
+		{
+			name:     "pursuit",
+			format:   configs.FormatPursuit,
+			expected: map[int]time.Duration{1: 6 * time.Minute, 2: 6*time.Minute + 10*time.Second},
+			order:    []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configs.Config{
+				Format:        tt.format,
+				Start:         configs.Clock(10 * time.Hour),
+				StartDelta:    configs.Duration(30 * time.Second),
+				Laps:          1,
+				LapLength:     4000,
+				PenaltyLength: 150,
+			}
+
+			processor := utils.NewProcessor(cfg)
+			for _, event := range events {
+				processor.Apply(event)
+			}
+
+			var order []int
+			for _, result := range processor.Standings() {
+				order = append(order, result.CompetitorID)
+				assert.Equal(t, tt.expected[result.CompetitorID], result.TotalTime)
+			}
+			assert.Equal(t, tt.order, order)
+		})
+	}
+}
+
+func TestProcessorMassStart(t *testing.T) {
+	cfg := &configs.Config{
+		Format:      configs.FormatMassStart,
+		Start:       configs.Clock(10 * time.Hour),
+		StartDelta:  configs.Duration(30 * time.Second),
+		Laps:        1,
+		FiringLines: 4,
+	}
+
+	processor := utils.NewProcessor(cfg)
+	processor.Apply(utils.Event{RawTime: "[09:50:00.000]", CompetitorID: 1, ID: 1})
+	processor.Apply(utils.Event{RawTime: "[09:50:00.000]", CompetitorID: 2, ID: 1})
+	processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4})
+	processor.Apply(utils.Event{RawTime: "[10:02:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "2"})
+	processor.Apply(utils.Event{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 7})
+	processor.Apply(utils.Event{RawTime: "[10:05:00.000]", CompetitorID: 1, ID: 5, ExtraParams: "3"})
+
+	competitor, ok := processor.Competitor(1)
+	require.True(t, ok)
+	assert.Equal(t, utils.PositionProne, competitor.RangeVisits[0].Position)
+	assert.Equal(t, utils.PositionStanding, competitor.RangeVisits[1].Position)
+
+	result, ok := processor.Result(2)
+	require.True(t, ok)
+	assert.Equal(t, "[NotStarted]", result.Status)
+}
+
+func TestProcessorShootingCourse(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:  configs.Duration(30 * time.Second),
+		Laps:        2,
+		FiringLines: 3,
+		Shooting: []configs.FiringLine{
+			{Position: "standing", Targets: 5, Shots: 5},
+			{Position: "standing", Targets: 3, Shots: 3},
+		},
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "5"},
+		{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:03:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "2"},
+		{RawTime: "[10:03:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:03:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:03:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:04:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "3"},
+		{RawTime: "[10:04:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:04:40.000]", CompetitorID: 1, ID: 7},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	competitor, ok := processor.Competitor(1)
+	require.True(t, ok)
+	assert.Len(t, competitor.RangeVisits[1].Targets, 3)
+	assert.Equal(t, utils.PositionProne, competitor.RangeVisits[2].Position)
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, "4/13", result.ShootingStats)
+	assert.Equal(t, "1/5", result.ProneStats)
+	assert.Equal(t, "3/8", result.StandingStats)
+}
+
+func TestProcessorLapLengths(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta: configs.Duration(30 * time.Second),
+		Laps:       3,
+		LapLength:  3000,
+		LapLengths: []int{3300, 3300, 3400},
+	}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:10:30.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:20:30.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:30:30.000]", CompetitorID: 1, ID: 10},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, []string{"5.500", "5.500", "5.667"}, result.AvgSpeeds)
+}
@@ -1,13 +1,27 @@
 package utils
 
 import (
-	"bufio"
-	"errors"
 	"fmt"
 	"io"
 	"os"
 )
 
+// Relay events.
+const (
+	EventHandOver   = 12
+	EventSpareRound = 13
+)
+
+// EventJuryDisqualification is a disqualification decided by the jury, with
+// the reason as its extra params.
+const EventJuryDisqualification = 14
+
+// Outgoing events are generated by the processor and never come from the input.
+const (
+	EventDisqualified = 32
+	EventFinished     = 33
+)
+
 type Event struct {
 	ID           int
 	RawTime      string
@@ -15,41 +29,16 @@ type Event struct {
 	ExtraParams  string
 }
 
-func parseEvents(fileIn *os.File) ([]Event, error) {
-	in := bufio.NewReader(fileIn)
-
-	var rawEventTime, extraParams string
-	var competitorID, eventID int
-	var events []Event
+func (e Event) IsOutgoing() bool {
+	return e.ID == EventDisqualified || e.ID == EventFinished
+}
 
-	for {
-		if _, err := fmt.Fscan(in, &rawEventTime, &eventID, &competitorID); err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			return nil, fmt.Errorf("error while scanning: %w", err)
-		}
-		if eventID == 2 || eventID == 5 || eventID == 6 || eventID == 11 {
-			_, err := fmt.Fscan(in, &extraParams)
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					break
-				}
-				if extraParams != "" {
-					return nil, fmt.Errorf("extra params missing: %w", err)
-				}
-				return nil, fmt.Errorf("error while scanning extra params: %w", err)
-			}
-		}
-		events = append(events, Event{
-			RawTime:      rawEventTime,
-			ID:           eventID,
-			CompetitorID: competitorID,
-			ExtraParams:  extraParams,
-		})
+// String formats the event the same way parseEvents reads it.
+func (e Event) String() string {
+	if e.ExtraParams == "" {
+		return fmt.Sprintf("%s %d %d", e.RawTime, e.ID, e.CompetitorID)
 	}
-
-	return events, nil
+	return fmt.Sprintf("%s %d %d %s", e.RawTime, e.ID, e.CompetitorID, e.ExtraParams)
 }
 
 func ReadEvents(path string) ([]Event, error) {
@@ -59,7 +48,24 @@ func ReadEvents(path string) ([]Event, error) {
 	}
 	defer func() { _ = fileIn.Close() }()
 
-	events, err := parseEvents(fileIn)
+	return ReadEventsAs(fileIn, EventFormatAuto, path)
+}
+
+// ReadEventsFrom parses events from r, e.g. standard input, detecting the
+// format from the first line.
+func ReadEventsFrom(r io.Reader) ([]Event, error) {
+	return ReadEventsAs(r, EventFormatAuto, "")
+}
+
+// ReadEventsAs parses events from r in the given format. name is only used to
+// detect the format from its extension.
+func ReadEventsAs(r io.Reader, format EventFormat, name string) ([]Event, error) {
+	decoder, err := NewEventDecoder(r, format, name)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := DecodeEvents(decoder)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse events: %w", err)
 	}
@@ -3,6 +3,7 @@ package utils_test
 import (
 	"biathlon-competitions-prototype/lib/utils"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -229,3 +230,24 @@ func TestParseEventsEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestEventStringRoundTrip(t *testing.T) {
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", ID: 1, CompetitorID: 101},
+		{RawTime: "[10:01:00.000]", ID: 2, CompetitorID: 101, ExtraParams: "10:05:00.000"},
+		{RawTime: "[10:06:00.000]", ID: utils.EventDisqualified, CompetitorID: 101},
+		{RawTime: "[10:07:00.000]", ID: utils.EventFinished, CompetitorID: 102},
+	}
+
+	var content string
+	for _, event := range events {
+		content += event.String() + "\n"
+	}
+
+	path := filepath.Join(t.TempDir(), "events")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	parsed, err := utils.ReadEvents(path)
+	require.NoError(t, err)
+	assert.Equal(t, events, parsed)
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+)
+
+type TeamResult struct {
+	TeamID       int
+	Status       string
+	Legs         []int
+	LegTimes     []string
+	LegDurations []time.Duration
+	PenaltyLoops int
+	SpareRounds  int
+	TotalTime    time.Duration
+	finished     bool
+}
+
+// Finished reports whether every leg of the team has been completed.
+func (r *TeamResult) Finished() bool {
+	return r.finished
+}
+
+// TeamStandings returns the relay teams ranked by total time, teams that have
+// not completed every leg come last.
+func (p *Processor) TeamStandings() []*TeamResult {
+	standings := make([]*TeamResult, 0, len(p.cfg.Relay.Teams))
+	for i := range p.cfg.Relay.Teams {
+		standings = append(standings, p.teamResult(&p.cfg.Relay.Teams[i]))
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].finished != standings[j].finished {
+			return standings[i].finished
+		}
+		if standings[i].TotalTime != standings[j].TotalTime {
+			return standings[i].TotalTime < standings[j].TotalTime
+		}
+		return standings[i].TeamID < standings[j].TeamID
+	})
+
+	return standings
+}
+
+func (p *Processor) teamResult(team *configs.Team) *TeamResult {
+	result := &TeamResult{TeamID: team.ID, Legs: team.Legs, finished: true}
+
+	legs := make(map[int]int)
+	for _, id := range team.Legs {
+		leg := legs[id]
+		legs[id]++
+
+		competitor, exists := p.competitors[id]
+		if !exists || leg >= len(competitor.LegTimes) {
+			result.finished = false
+			result.LegTimes = append(result.LegTimes, "")
+			result.LegDurations = append(result.LegDurations, 0)
+			continue
+		}
+
+		result.LegTimes = append(result.LegTimes, FormatDurationToTime(competitor.LegTimes[leg]))
+		result.LegDurations = append(result.LegDurations, competitor.LegTimes[leg])
+		result.TotalTime += competitor.LegTimes[leg]
+	}
+
+	var last *Competitor
+	if len(team.Legs) > 0 {
+		last = p.competitors[team.Legs[len(team.Legs)-1]]
+	}
+	if last != nil {
+		result.Status = last.FinishTime.Format("15:04:05.000")
+	}
+
+	for id := range legs {
+		competitor, exists := p.competitors[id]
+		if !exists {
+			continue
+		}
+
+		switch {
+		case competitor.DisqualifiedByJury:
+			result.Status = StatusDisqualified
+			result.finished = false
+		case competitor.IsDisqualified && result.Status != StatusDisqualified:
+			result.Status = StatusNotStarted
+			result.finished = false
+		case competitor.IsNotFinished && result.Status != StatusNotStarted && result.Status != StatusDisqualified:
+			result.Status = StatusNotFinished
+			result.finished = false
+		}
+
+		for _, visit := range competitor.RangeVisits {
+			if visit != competitor.CurrentVisit() {
+				result.PenaltyLoops += visit.Misses()
+			}
+			result.SpareRounds += visit.SpareRounds
+		}
+	}
+
+	return result
+}
+
+// FormatTeamResult formats a team the same way FormatResult formats a
+// competitor: status, team, legs and penalty loops + spare rounds. A finish
+// time is bracketed, the other statuses already are.
+func FormatTeamResult(result *TeamResult) string {
+	var builder strings.Builder
+
+	if strings.HasPrefix(result.Status, "[") {
+		builder.WriteString(result.Status)
+	} else {
+		builder.WriteString("[" + result.Status + "]")
+	}
+	builder.WriteString(" ")
+	builder.WriteString(strconv.Itoa(result.TeamID))
+
+	builder.WriteString(" [")
+	for i, athlete := range result.Legs {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(fmt.Sprintf("{%d, %s}", athlete, result.LegTimes[i]))
+	}
+	builder.WriteString("] ")
+
+	builder.WriteString(fmt.Sprintf("%d+%d", result.PenaltyLoops, result.SpareRounds))
+
+	return builder.String()
+}
+
+// currentLeg returns the number of legs of the team that have been started.
+func (p *Processor) currentLeg(team *configs.Team) int {
+	leg := 0
+	seen := make(map[int]bool)
+	for _, id := range team.Legs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if competitor, exists := p.competitors[id]; exists {
+			leg += competitor.Legs
+		}
+	}
+	return leg
+}
+
+// startsWithRace reports whether the competitor starts together with the
+// race start instead of a draw or a hand-over.
+func (p *Processor) startsWithRace(id int) bool {
+	if p.cfg.Format == configs.FormatMassStart {
+		return true
+	}
+
+	team, exists := p.teams[id]
+	return exists && len(team.Legs) > 0 && team.Legs[0] == id
+}
+
+func (p *Processor) startLeg(competitor *Competitor, startTime time.Time) {
+	competitor.ActualStart = startTime
+	competitor.LapStart = startTime
+	competitor.HasStarted = true
+
+	if p.cfg.Format.IsRelay() {
+		competitor.Legs++
+		competitor.IsFinishedCompletely = false
+	}
+}
+
+// lapsFor returns the number of main laps after which the competitor finishes.
+// Relay athletes finish every leg they ski.
+func (p *Processor) lapsFor(competitor *Competitor) int {
+	if !p.cfg.Format.IsRelay() {
+		return p.cfg.Laps
+	}
+	return p.cfg.Laps * max(1, competitor.Legs)
+}
+
+// rejectRelayEvent returns why a hand-over or spare round cannot be applied,
+// or "" when it can. Such an event leaves the race unchanged.
+func (p *Processor) rejectRelayEvent(event Event) string {
+	if event.ID != EventHandOver && event.ID != EventSpareRound {
+		return ""
+	}
+	if !p.cfg.Format.IsRelay() {
+		return "not a relay race"
+	}
+	if event.ID == EventHandOver {
+		if nextID, err := strconv.Atoi(event.ExtraParams); err != nil || nextID < 1 {
+			return fmt.Sprintf("invalid next competitor %q", event.ExtraParams)
+		}
+	}
+	return ""
+}
+
+func (p *Processor) handOver(competitor *Competitor, event Event, eventTime time.Time) string {
+	nextID, _ := strconv.Atoi(event.ExtraParams)
+	next := p.competitor(nextID)
+	p.startLeg(next, eventTime)
+	p.summarize(next)
+
+	return fmt.Sprintf("%s The competitor(%d) handed over to the competitor(%d)", event.RawTime, competitor.ID, nextID)
+}
+
+// spareRounds returns the spare rounds allowed on the firing range. A firing
+// line definition with more shots than targets takes precedence.
+func spareRounds(cfg *configs.Config, firingRange int) int {
+	if line, ok := firingLine(cfg, firingRange); ok && line.Shots > line.Targets {
+		return line.Shots - line.Targets
+	}
+	return cfg.Relay.SpareRounds
+}
@@ -0,0 +1,228 @@
+package utils_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func relayConfig(format configs.Format, teams ...configs.Team) *configs.Config {
+	return &configs.Config{
+		Format:        format,
+		Start:         configs.Clock(10 * time.Hour),
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     3000,
+		PenaltyLength: 75,
+		FiringLines:   1,
+		Relay:         configs.Relay{Teams: teams},
+	}
+}
+
+func TestRelayTeamStandings(t *testing.T) {
+	cfg := relayConfig(
+		configs.FormatRelay,
+		configs.Team{ID: 1, Legs: []int{11, 12}},
+		configs.Team{ID: 2, Legs: []int{21, 22}},
+	)
+
+	events := []utils.Event{
+		{RawTime: "[09:50:00.000]", CompetitorID: 11, ID: 1},
+		{RawTime: "[09:50:00.000]", CompetitorID: 12, ID: 1},
+		{RawTime: "[09:50:00.000]", CompetitorID: 21, ID: 1},
+		{RawTime: "[09:50:00.000]", CompetitorID: 22, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 11, ID: 4},
+		{RawTime: "[10:00:00.000]", CompetitorID: 21, ID: 4},
+		{RawTime: "[10:03:00.000]", CompetitorID: 11, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:03:01.000]", CompetitorID: 11, ID: 6, ExtraParams: "1"},
+		{RawTime: "[10:03:02.000]", CompetitorID: 11, ID: 6, ExtraParams: "2"},
+		{RawTime: "[10:03:03.000]", CompetitorID: 11, ID: 6, ExtraParams: "3"},
+		{RawTime: "[10:03:04.000]", CompetitorID: 11, ID: 13},
+		{RawTime: "[10:03:05.000]", CompetitorID: 11, ID: 6, ExtraParams: "4"},
+		{RawTime: "[10:03:06.000]", CompetitorID: 11, ID: 13},
+		{RawTime: "[10:03:10.000]", CompetitorID: 11, ID: 7},
+		{RawTime: "[10:03:15.000]", CompetitorID: 11, ID: 8},
+		{RawTime: "[10:03:45.000]", CompetitorID: 11, ID: 9},
+		{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: 10},
+		{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventHandOver, ExtraParams: "12"},
+		{RawTime: "[10:09:00.000]", CompetitorID: 21, ID: 10},
+		{RawTime: "[10:09:00.000]", CompetitorID: 21, ID: utils.EventHandOver, ExtraParams: "22"},
+		{RawTime: "[10:16:00.000]", CompetitorID: 12, ID: 10},
+		{RawTime: "[10:16:30.000]", CompetitorID: 22, ID: 11, ExtraParams: "Broken"},
+	}
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	assert.Contains(t, processor.Output(), "[10:08:00.000] The competitor(11) handed over to the competitor(12)")
+	assert.Contains(t, processor.Output(), "[10:03:04.000] The competitor(11) loaded a spare round")
+
+	result, ok := processor.Result(11)
+	require.True(t, ok)
+	assert.Equal(t, []string{"4/7"}, result.VisitStats)
+
+	standings := processor.TeamStandings()
+	require.Len(t, standings, 2)
+
+	assert.Equal(t, 1, standings[0].TeamID)
+	assert.Equal(t, "10:16:00.000", standings[0].Status)
+	assert.Equal(t, 16*time.Minute, standings[0].TotalTime)
+	assert.Equal(t, 1, standings[0].PenaltyLoops)
+	assert.Equal(t, 2, standings[0].SpareRounds)
+	assert.Equal(t, "[10:16:00.000] 1 [{11, 00:08:00.000}, {12, 00:08:00.000}] 1+2", utils.FormatTeamResult(standings[0]))
+
+	assert.Equal(t, 2, standings[1].TeamID)
+	assert.Equal(t, "[NotFinished]", standings[1].Status)
+	assert.Equal(t, "[NotFinished] 2 [{21, 00:09:00.000}, {22, }] 0+0", utils.FormatTeamResult(standings[1]))
+}
+
+func TestRelayEventsRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		format configs.Format
+		event  utils.Event
+		reason string
+	}{
+		{
+			name:   "Missing next competitor",
+			format: configs.FormatRelay,
+			event:  utils.Event{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventHandOver},
+			reason: `invalid next competitor ""`,
+		},
+		{
+			name:   "Malformed next competitor",
+			format: configs.FormatRelay,
+			event:  utils.Event{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventHandOver, ExtraParams: "x"},
+			reason: `invalid next competitor "x"`,
+		},
+		{
+			name:   "Hand-over outside a relay",
+			format: configs.FormatSprint,
+			event:  utils.Event{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventHandOver, ExtraParams: "12"},
+			reason: "not a relay race",
+		},
+		{
+			name:   "Spare round outside a relay",
+			format: configs.FormatSprint,
+			event:  utils.Event{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventSpareRound},
+			reason: "not a relay race",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := relayConfig(tt.format, configs.Team{ID: 1, Legs: []int{11, 12}})
+			processor := utils.NewProcessor(cfg)
+			processor.Apply(utils.Event{RawTime: "[09:50:00.000]", CompetitorID: 11, ID: 1})
+			processor.Apply(utils.Event{RawTime: "[10:00:00.000]", CompetitorID: 11, ID: 4})
+
+			lines := processor.Apply(tt.event)
+
+			assert.Equal(t, []string{
+				"[10:08:00.000] The event(" + strconv.Itoa(tt.event.ID) + ") of the competitor(11) is ignored: " + tt.reason,
+			}, lines)
+			assert.Len(t, processor.Events(), 2)
+			assert.Len(t, processor.Results(), 1)
+			_, ok := processor.Competitor(0)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestSingleMixedRelayLegs(t *testing.T) {
+	cfg := relayConfig(configs.FormatSingleMixedRelay, configs.Team{ID: 1, Legs: []int{1, 2, 1, 2}})
+	cfg.FiringLines = 0
+
+	events := []utils.Event{
+		{RawTime: "[09:50:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[09:50:00.000]", CompetitorID: 2, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:03:00.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:03:00.000]", CompetitorID: 1, ID: utils.EventHandOver, ExtraParams: "2"},
+		{RawTime: "[10:06:00.000]", CompetitorID: 2, ID: 10},
+		{RawTime: "[10:06:00.000]", CompetitorID: 2, ID: utils.EventHandOver, ExtraParams: "1"},
+		{RawTime: "[10:09:30.000]", CompetitorID: 1, ID: 10},
+		{RawTime: "[10:09:30.000]", CompetitorID: 1, ID: utils.EventHandOver, ExtraParams: "2"},
+		{RawTime: "[10:13:00.000]", CompetitorID: 2, ID: 10},
+	}
+
+	diagnostics, err := utils.ValidateEvents(cfg, events, true)
+	require.NoError(t, err)
+	assert.Empty(t, diagnostics)
+
+	processor := utils.NewProcessor(cfg)
+	for _, event := range events {
+		processor.Apply(event)
+	}
+
+	result, ok := processor.Result(1)
+	require.True(t, ok)
+	assert.Equal(t, 2, result.Laps)
+	assert.Equal(t, []string{"00:03:00.000", "00:03:30.000"}, result.LapTimes)
+
+	standings := processor.TeamStandings()
+	require.Len(t, standings, 1)
+	assert.Equal(t, []string{"00:03:00.000", "00:03:00.000", "00:03:30.000", "00:03:30.000"}, standings[0].LegTimes)
+	assert.Equal(t, 13*time.Minute, standings[0].TotalTime)
+}
+
+func TestValidateRelayEvents(t *testing.T) {
+	cfg := relayConfig(configs.FormatRelay, configs.Team{ID: 1, Legs: []int{11, 12, 13}})
+	cfg.Relay.SpareRounds = 1
+
+	events := []utils.Event{
+		{RawTime: "[09:50:00.000]", CompetitorID: 11, ID: 1},
+		{RawTime: "[09:50:00.000]", CompetitorID: 12, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 11, ID: 4},
+		{RawTime: "[10:02:00.000]", CompetitorID: 11, ID: 13},
+		{RawTime: "[10:03:00.000]", CompetitorID: 11, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:03:01.000]", CompetitorID: 11, ID: 13},
+		{RawTime: "[10:03:02.000]", CompetitorID: 11, ID: 13},
+		{RawTime: "[10:03:10.000]", CompetitorID: 11, ID: 7},
+		{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: 10},
+		{RawTime: "[10:08:00.000]", CompetitorID: 11, ID: utils.EventHandOver, ExtraParams: "13"},
+		{RawTime: "[10:08:01.000]", CompetitorID: 12, ID: utils.EventHandOver, ExtraParams: "11"},
+	}
+
+	diagnostics, err := utils.ValidateEvents(cfg, events, false)
+	require.NoError(t, err)
+
+	var rules []utils.Rule
+	for _, diagnostic := range diagnostics {
+		rules = append(rules, diagnostic.Rule)
+	}
+	assert.Equal(t, []utils.Rule{
+		utils.RuleSpareRounds,
+		utils.RuleSpareRounds,
+		utils.RuleHandOver,
+		utils.RuleHandOver,
+	}, rules)
+
+	diagnostics, err = utils.ValidateEvents(relayConfig(configs.FormatSprint), []utils.Event{events[0], events[3]}, false)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, utils.RuleUnknownEvent, diagnostics[0].Rule)
+}
+
+func TestValidateNoSpareRounds(t *testing.T) {
+	cfg := relayConfig(configs.FormatRelay, configs.Team{ID: 1, Legs: []int{11, 12}})
+
+	diagnostics, err := utils.ValidateEvents(cfg, []utils.Event{
+		{RawTime: "[09:50:00.000]", CompetitorID: 11, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 11, ID: 4},
+		{RawTime: "[10:03:00.000]", CompetitorID: 11, ID: 5, ExtraParams: "1"},
+		{RawTime: "[10:03:01.000]", CompetitorID: 11, ID: 13},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, utils.RuleSpareRounds, diagnostics[0].Rule)
+	assert.Equal(t, "more than 0 spare rounds", diagnostics[0].Message)
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+)
+
+const (
+	defaultShotsPerLine = 5
+	individualPenalty   = time.Minute
+)
+
+type Position string
+
+const (
+	PositionProne    Position = "prone"
+	PositionStanding Position = "standing"
+)
+
+// RangeVisit is a single stay of a competitor on a firing range.
+type RangeVisit struct {
+	Range         int
+	Position      Position
+	Lap           int
+	Targets       []bool
+	DuplicateHits []int
+	SpareRounds   int
+	PenaltyTime   time.Duration
+	// PenaltyLoops is the estimated number of loops skied after the visit, at
+	// most the misses.
+	PenaltyLoops int
+	// Closed is set once the competitor can no longer ski penalty loops for the visit.
+	Closed bool
+	// TimePenalty is set in the individual race, where a miss costs time instead of a loop.
+	TimePenalty bool
+}
+
+func NewRangeVisit(firingRange int, lap int, shots int) *RangeVisit {
+	return &RangeVisit{
+		Range:   firingRange,
+		Lap:     lap,
+		Targets: make([]bool, shots),
+	}
+}
+
+// Hit records a hit on the target and reports whether it was the first hit on it.
+func (v *RangeVisit) Hit(target int) bool {
+	if target < 1 || target > len(v.Targets) {
+		return false
+	}
+
+	if v.Targets[target-1] {
+		v.DuplicateHits = append(v.DuplicateHits, target)
+		return false
+	}
+
+	v.Targets[target-1] = true
+	return true
+}
+
+func (v *RangeVisit) Hits() int {
+	hits := 0
+	for _, hit := range v.Targets {
+		if hit {
+			hits++
+		}
+	}
+	return hits
+}
+
+func (v *RangeVisit) Misses() int {
+	return len(v.Targets) - v.Hits()
+}
+
+// RequiredLoops returns the penalty loops owed for the misses.
+func (v *RangeVisit) RequiredLoops() int {
+	if v.TimePenalty {
+		return 0
+	}
+	return v.Misses()
+}
+
+// SkippedLoops returns the penalty loops required by the misses but not skied.
+func (v *RangeVisit) SkippedLoops() int {
+	if !v.Closed {
+		return 0
+	}
+	return max(0, v.RequiredLoops()-v.PenaltyLoops)
+}
+
+// Shots returns the rounds fired: one per target plus the spare rounds loaded by hand.
+func (v *RangeVisit) Shots() int {
+	return len(v.Targets) + v.SpareRounds
+}
+
+func (v *RangeVisit) String() string {
+	return fmt.Sprintf("%d/%d", v.Hits(), v.Shots())
+}
+
+// shotsPerLine returns the configured number of shots per firing line, falling
+// back to the standard five when the config leaves it unset.
+func shotsPerLine(cfg *configs.Config) int {
+	if cfg.ShotsPerLine <= 0 {
+		return defaultShotsPerLine
+	}
+	return cfg.ShotsPerLine
+}
+
+// firingLine returns the course definition of the firing range, if configured.
+func firingLine(cfg *configs.Config, firingRange int) (configs.FiringLine, bool) {
+	if firingRange < 1 || firingRange > len(cfg.Shooting) {
+		return configs.FiringLine{}, false
+	}
+	return cfg.Shooting[firingRange-1], true
+}
+
+// newRangeVisit opens a visit shaped by the firing line definition, or by the
+// race format defaults when the course does not define the line.
+func newRangeVisit(cfg *configs.Config, firingRange int, lap int) *RangeVisit {
+	targets := shotsPerLine(cfg)
+	position := shootingPosition(cfg.Format, firingRange)
+
+	if line, ok := firingLine(cfg, firingRange); ok {
+		if line.Targets > 0 {
+			targets = line.Targets
+		}
+		if line.Position != "" {
+			position = Position(line.Position)
+		}
+	}
+
+	visit := NewRangeVisit(firingRange, lap, targets)
+	visit.Position = position
+	visit.TimePenalty = cfg.Format == configs.FormatIndividual
+
+	return visit
+}
+
+// shootingPosition returns the position of the firing line in the fixed
+// shooting order of the race format.
+func shootingPosition(format configs.Format, firingRange int) Position {
+	var order []Position
+	switch format {
+	case configs.FormatIndividual:
+		order = []Position{PositionProne, PositionStanding, PositionProne, PositionStanding}
+	case configs.FormatPursuit, configs.FormatMassStart:
+		order = []Position{PositionProne, PositionProne, PositionStanding, PositionStanding}
+	default:
+		order = []Position{PositionProne, PositionStanding}
+	}
+
+	if firingRange < 1 {
+		return ""
+	}
+	return order[(firingRange-1)%len(order)]
+}
+
+// requiredPenaltyLoops returns the loops owed for the visit preceding a penalty
+// block. Without a known visit the block is counted as a single loop.
+func requiredPenaltyLoops(visit *RangeVisit) int {
+	if visit == nil || visit.Misses() == 0 {
+		return 1
+	}
+	return visit.Misses()
+}
+
+// referenceLoopTime returns the time a penalty loop is expected to take: the
+// loop length at the configured penaltySpeed, or else the median time per loop
+// of the race so far. It is zero while nothing is known.
+func (p *Processor) referenceLoopTime() time.Duration {
+	if p.cfg.PenaltySpeed > 0 {
+		return time.Duration(float64(p.cfg.PenaltyLength) / p.cfg.PenaltySpeed * float64(time.Second))
+	}
+	if len(p.loopTimes) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(p.loopTimes)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// reestimate updates the loops skied of every other competitor after a new
+// penalty block moved the median loop time of the race.
+func (p *Processor) reestimate(except *Competitor) {
+	if p.cfg.PenaltySpeed > 0 {
+		return
+	}
+	for _, competitor := range p.competitors {
+		if competitor != except {
+			p.summarize(competitor)
+		}
+	}
+}
+
+// estimatePenaltyLoops returns how many penalty loops were skied after the
+// visit, rounding its penalty time to whole reference loops. It never exceeds
+// the misses, and without a reference every owed loop is taken as skied.
+func estimatePenaltyLoops(visit *RangeVisit, reference time.Duration) int {
+	if visit.PenaltyTime <= 0 {
+		return 0
+	}
+	if reference <= 0 {
+		return visit.Misses()
+	}
+
+	loops := int(math.Round(float64(visit.PenaltyTime) / float64(reference)))
+	return min(loops, visit.Misses())
+}
+
+func parseTarget(extraParams string) int {
+	target, err := strconv.Atoi(extraParams)
+	if err != nil {
+		return 0
+	}
+	return target
+}
@@ -0,0 +1,27 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func TestRangeVisit(t *testing.T) {
+	visit := utils.NewRangeVisit(2, 1, 5)
+
+	assert.True(t, visit.Hit(1))
+	assert.True(t, visit.Hit(4))
+	assert.False(t, visit.Hit(4))
+	assert.False(t, visit.Hit(6))
+	assert.False(t, visit.Hit(0))
+
+	assert.Equal(t, 2, visit.Range)
+	assert.Equal(t, 1, visit.Lap)
+	assert.Equal(t, []bool{true, false, false, true, false}, visit.Targets)
+	assert.Equal(t, []int{4}, visit.DuplicateHits)
+	assert.Equal(t, 2, visit.Hits())
+	assert.Equal(t, 3, visit.Misses())
+	assert.Equal(t, "2/5", visit.String())
+}
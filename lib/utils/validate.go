@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"biathlon-competitions-prototype/configs"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Rule identifies the check a diagnostic was produced by.
+type Rule string
+
+const (
+	RuleInvalidTime         Rule = "invalid-time"
+	RuleOutOfOrder          Rule = "out-of-order"
+	RuleUnknownEvent        Rule = "unknown-event"
+	RuleInvalidParams       Rule = "invalid-params"
+	RuleUnregistered        Rule = "unregistered"
+	RuleDuplicateRegistered Rule = "duplicate-registration"
+	RuleNoDraw              Rule = "no-draw"
+	RuleNotStarted          Rule = "not-started"
+	RuleHitOffRange         Rule = "hit-off-range"
+	RuleDuplicateHit        Rule = "duplicate-hit"
+	RuleRangeNotEntered     Rule = "range-not-entered"
+	RulePenaltyNotEntered   Rule = "penalty-not-entered"
+	RuleNoPenaltyLoops      Rule = "no-penalty-loops"
+	RuleLapAfterFinish      Rule = "lap-after-finish"
+	RuleFiringLines         Rule = "firing-lines-mismatch"
+	RuleAfterWithdrawal     Rule = "after-withdrawal"
+	RuleHandOver            Rule = "hand-over"
+	RuleSpareRounds         Rule = "spare-rounds"
+)
+
+type Diagnostic struct {
+	Severity     Severity
+	Index        int
+	CompetitorID int
+	Rule         Rule
+	Message      string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: event %d (competitor %d): %s: %s", d.Severity, d.Index, d.CompetitorID, d.Rule, d.Message)
+}
+
+type ValidationError struct {
+	Diagnostic Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid event stream: " + e.Diagnostic.String()
+}
+
+// Validator checks an event stream against the competitor state machine. It
+// keeps its own Processor so it can be placed in front of a live one.
+type Validator struct {
+	cfg         *configs.Config
+	strict      bool
+	processor   *Processor
+	acceptLate  bool
+	index       int
+	lastTime    time.Time
+	diagnostics []Diagnostic
+}
+
+func NewValidator(cfg *configs.Config, strict bool) *Validator {
+	return &Validator{
+		cfg:       cfg,
+		strict:    strict,
+		processor: NewProcessor(cfg),
+	}
+}
+
+// AcceptLate makes an event older than the previous one a warning rather than
+// an error, for streams merged from sources whose events may arrive late.
+func (v *Validator) AcceptLate() {
+	v.acceptLate = true
+}
+
+// Check validates the next event in the stream. In strict mode the first
+// error-level diagnostic is returned as a *ValidationError.
+func (v *Validator) Check(event Event) error {
+	index := v.index
+	v.index++
+	found := len(v.diagnostics)
+
+	eventTime, err := parseRawTime(event.RawTime)
+	if err != nil {
+		v.report(SeverityError, index, event, RuleInvalidTime, fmt.Sprintf("cannot parse time %q", event.RawTime))
+		return v.firstError(found)
+	}
+
+	if !v.lastTime.IsZero() && eventTime.Before(v.lastTime) {
+		severity := SeverityError
+		if v.acceptLate {
+			severity = SeverityWarning
+		}
+		v.report(
+			severity,
+			index,
+			event,
+			RuleOutOfOrder,
+			fmt.Sprintf("time %s is before the previous event", event.RawTime),
+		)
+	} else {
+		v.lastTime = eventTime
+	}
+
+	v.checkState(index, event)
+
+	if err := v.firstError(found); err != nil {
+		return err
+	}
+
+	v.processor.Apply(event)
+
+	return nil
+}
+
+func (v *Validator) Diagnostics() []Diagnostic {
+	return v.diagnostics
+}
+
+// ValidateEvents runs every event through a Validator and returns the diagnostics.
+func ValidateEvents(cfg *configs.Config, events []Event, strict bool) ([]Diagnostic, error) {
+	validator := NewValidator(cfg, strict)
+
+	for _, event := range events {
+		if err := validator.Check(event); err != nil {
+			return validator.Diagnostics(), err
+		}
+	}
+
+	return validator.Diagnostics(), nil
+}
+
+func (v *Validator) checkState(index int, event Event) {
+	competitor, exists := v.processor.Competitor(event.CompetitorID)
+
+	if event.ID == 1 {
+		if exists && competitor.Registered {
+			v.report(SeverityWarning, index, event, RuleDuplicateRegistered, "competitor is already registered")
+		}
+		return
+	}
+
+	if !knownEvent(event.ID, v.cfg.Format) {
+		v.report(SeverityError, index, event, RuleUnknownEvent, fmt.Sprintf("unknown event id %d", event.ID))
+		return
+	}
+
+	if !exists || !competitor.Registered {
+		v.report(SeverityError, index, event, RuleUnregistered, "competitor is not registered")
+		return
+	}
+
+	if competitor.IsNotFinished || competitor.DisqualifiedByJury {
+		v.report(SeverityWarning, index, event, RuleAfterWithdrawal, "competitor can`t continue already")
+	}
+
+	switch event.ID {
+	case 2:
+		if _, err := ParseTime(event.ExtraParams); err != nil {
+			v.report(
+				SeverityError,
+				index,
+				event,
+				RuleInvalidParams,
+				fmt.Sprintf("cannot parse start time %q", event.ExtraParams),
+			)
+		}
+	case 3, 4:
+		if competitor.PlannedStart.IsZero() {
+			v.report(SeverityError, index, event, RuleNoDraw, "start time was not set by a draw")
+		}
+	case 5:
+		v.checkFiringRange(index, event)
+		v.checkStarted(index, event, competitor)
+	case 6:
+		v.checkTarget(index, event, competitor)
+	case 7:
+		if !competitor.OnFiringRange {
+			v.report(SeverityError, index, event, RuleRangeNotEntered, "left the firing range without entering it")
+		}
+	case 8:
+		v.checkStarted(index, event, competitor)
+		if v.cfg.Format == configs.FormatIndividual {
+			v.report(SeverityError, index, event, RuleNoPenaltyLoops, "individual races have no penalty loops")
+		}
+	case 9:
+		if !competitor.OnPenaltyLoop {
+			v.report(SeverityError, index, event, RulePenaltyNotEntered, "left the penalty laps without entering them")
+		}
+	case 10:
+		v.checkStarted(index, event, competitor)
+		if competitor.IsFinishedCompletely {
+			v.report(SeverityError, index, event, RuleLapAfterFinish, "main lap ended after the finish")
+		} else if competitor.CurrentLap+1 >= v.processor.lapsFor(competitor) {
+			v.checkVisits(index, event, competitor)
+		}
+	case EventHandOver:
+		v.checkHandOver(index, event, competitor)
+	case EventSpareRound:
+		v.checkSpareRound(index, event, competitor)
+	case EventJuryDisqualification:
+		if event.ExtraParams == "" {
+			v.report(SeverityError, index, event, RuleInvalidParams, "a disqualification needs a reason")
+		}
+	}
+}
+
+// knownEvent reports whether the incoming event id exists in the race format:
+// the events 1-11, the jury disqualification and in relays the relay events.
+func knownEvent(id int, format configs.Format) bool {
+	switch id {
+	case EventJuryDisqualification:
+		return true
+	case EventHandOver, EventSpareRound:
+		return format.IsRelay()
+	default:
+		return id >= 1 && id <= 11
+	}
+}
+
+func (v *Validator) checkStarted(index int, event Event, competitor *Competitor) {
+	if !competitor.HasStarted {
+		v.report(SeverityError, index, event, RuleNotStarted, "competitor has not started")
+	}
+}
+
+func (v *Validator) checkTarget(index int, event Event, competitor *Competitor) {
+	visit := competitor.CurrentVisit()
+	if visit == nil {
+		v.report(SeverityError, index, event, RuleHitOffRange, "target hit while not on the firing range")
+		return
+	}
+
+	target := parseTarget(event.ExtraParams)
+	if target < 1 || target > len(visit.Targets) {
+		v.report(SeverityError, index, event, RuleInvalidParams, fmt.Sprintf("invalid target %q", event.ExtraParams))
+		return
+	}
+
+	if visit.Targets[target-1] {
+		v.report(SeverityWarning, index, event, RuleDuplicateHit, fmt.Sprintf("target %d is already hit", target))
+	}
+}
+
+func (v *Validator) checkFiringRange(index int, event Event) {
+	firingRange, err := strconv.Atoi(event.ExtraParams)
+	if err != nil || firingRange < 1 || (v.cfg.FiringLines > 0 && firingRange > v.cfg.FiringLines) {
+		v.report(SeverityError, index, event, RuleInvalidParams, fmt.Sprintf("invalid firing range %q", event.ExtraParams))
+	}
+}
+
+// checkVisits compares the range visits of a finishing competitor with the
+// number of firing lines in the race.
+func (v *Validator) checkVisits(index int, event Event, competitor *Competitor) {
+	expected := v.cfg.FiringLines
+	if v.cfg.Format.IsRelay() {
+		expected *= max(1, competitor.Legs)
+	}
+
+	if expected <= 0 || len(competitor.RangeVisits) == expected {
+		return
+	}
+
+	v.report(
+		SeverityWarning,
+		index,
+		event,
+		RuleFiringLines,
+		fmt.Sprintf("%d firing range visits, expected %d", len(competitor.RangeVisits), expected),
+	)
+}
+
+// checkHandOver verifies that the competitor is on the current leg of the team
+// and hands over to the athlete of the next leg.
+func (v *Validator) checkHandOver(index int, event Event, competitor *Competitor) {
+	team, exists := v.processor.teams[competitor.ID]
+	if !exists {
+		v.report(SeverityError, index, event, RuleHandOver, "competitor is not in a relay team")
+		return
+	}
+
+	leg := v.processor.currentLeg(team)
+	switch {
+	case leg == 0 || team.Legs[leg-1] != competitor.ID:
+		v.report(SeverityError, index, event, RuleHandOver, "competitor is not on the current leg")
+	case leg >= len(team.Legs):
+		v.report(SeverityError, index, event, RuleHandOver, "no legs left to hand over to")
+	case strconv.Itoa(team.Legs[leg]) != event.ExtraParams:
+		v.report(
+			SeverityError,
+			index,
+			event,
+			RuleHandOver,
+			fmt.Sprintf("expected hand-over to competitor %d, got %q", team.Legs[leg], event.ExtraParams),
+		)
+	}
+}
+
+func (v *Validator) checkSpareRound(index int, event Event, competitor *Competitor) {
+	visit := competitor.CurrentVisit()
+	if visit == nil {
+		v.report(SeverityError, index, event, RuleSpareRounds, "spare round loaded while not on the firing range")
+		return
+	}
+
+	if limit := spareRounds(v.cfg, visit.Range); visit.SpareRounds >= limit {
+		v.report(SeverityError, index, event, RuleSpareRounds, fmt.Sprintf("more than %d spare rounds", limit))
+	}
+}
+
+func (v *Validator) report(severity Severity, index int, event Event, rule Rule, message string) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Severity:     severity,
+		Index:        index,
+		CompetitorID: event.CompetitorID,
+		Rule:         rule,
+		Message:      message,
+	})
+}
+
+func (v *Validator) firstError(from int) error {
+	if !v.strict {
+		return nil
+	}
+
+	for _, diagnostic := range v.diagnostics[from:] {
+		if diagnostic.Severity == SeverityError {
+			return &ValidationError{Diagnostic: diagnostic}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package utils_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"biathlon-competitions-prototype/configs"
+	"biathlon-competitions-prototype/lib/utils"
+)
+
+func TestValidateEvents(t *testing.T) {
+	cfg := &configs.Config{
+		StartDelta:    configs.Duration(30 * time.Second),
+		Laps:          1,
+		LapLength:     4000,
+		PenaltyLength: 150,
+	}
+
+	tests := []struct {
+		name     string
+		events   []utils.Event
+		expected []utils.Rule
+	}{
+		{
+			name: "valid stream",
+			events: []utils.Event{
+				{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+				{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+				{RawTime: "[10:00:29.000]", CompetitorID: 1, ID: 3},
+				{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+				{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+				{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+				{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+				{RawTime: "[10:02:45.000]", CompetitorID: 1, ID: 8},
+				{RawTime: "[10:02:55.000]", CompetitorID: 1, ID: 9},
+				{RawTime: "[10:03:30.000]", CompetitorID: 1, ID: 10},
+			},
+		},
+		{
+			name: "broken time and out of order",
+			events: []utils.Event{
+				{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+				{RawTime: "10:00:01", CompetitorID: 1, ID: 1},
+				{RawTime: "[09:59:00.000]", CompetitorID: 2, ID: 1},
+			},
+			expected: []utils.Rule{utils.RuleInvalidTime, utils.RuleOutOfOrder},
+		},
+		{
+			name: "unregistered and unknown event",
+			events: []utils.Event{
+				{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 4},
+				{RawTime: "[10:00:01.000]", CompetitorID: 2, ID: 1},
+				{RawTime: "[10:00:02.000]", CompetitorID: 2, ID: 42},
+				{RawTime: "[10:00:03.000]", CompetitorID: 2, ID: 1},
+			},
+			expected: []utils.Rule{utils.RuleUnregistered, utils.RuleUnknownEvent, utils.RuleDuplicateRegistered},
+		},
+		{
+			name: "duplicate and invalid targets",
+			events: []utils.Event{
+				{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+				{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+				{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+				{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "1"},
+				{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+				{RawTime: "[10:02:32.000]", CompetitorID: 1, ID: 6, ExtraParams: "2"},
+				{RawTime: "[10:02:33.000]", CompetitorID: 1, ID: 6, ExtraParams: "7"},
+			},
+			expected: []utils.Rule{utils.RuleDuplicateHit, utils.RuleInvalidParams},
+		},
+		{
+			name: "state machine violations",
+			events: []utils.Event{
+				{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+				{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 3},
+				{RawTime: "[10:00:02.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+				{RawTime: "[10:00:20.000]", CompetitorID: 1, ID: 5, ExtraParams: "x"},
+				{RawTime: "[10:00:21.000]", CompetitorID: 1, ID: 7},
+				{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+				{RawTime: "[10:02:31.000]", CompetitorID: 1, ID: 6, ExtraParams: "1"},
+				{RawTime: "[10:02:32.000]", CompetitorID: 1, ID: 7},
+				{RawTime: "[10:02:55.000]", CompetitorID: 1, ID: 9},
+				{RawTime: "[10:03:30.000]", CompetitorID: 1, ID: 10},
+				{RawTime: "[10:06:30.000]", CompetitorID: 1, ID: 10},
+				{RawTime: "[10:06:31.000]", CompetitorID: 1, ID: 11, ExtraParams: "Tired"},
+				{RawTime: "[10:06:32.000]", CompetitorID: 1, ID: 8},
+			},
+			expected: []utils.Rule{
+				utils.RuleNoDraw,
+				utils.RuleInvalidParams,
+				utils.RuleNotStarted,
+				utils.RuleHitOffRange,
+				utils.RuleRangeNotEntered,
+				utils.RulePenaltyNotEntered,
+				utils.RuleLapAfterFinish,
+				utils.RuleAfterWithdrawal,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := utils.ValidateEvents(cfg, tt.events, false)
+			require.NoError(t, err)
+
+			var rules []utils.Rule
+			for _, diagnostic := range diagnostics {
+				rules = append(rules, diagnostic.Rule)
+			}
+			assert.Equal(t, tt.expected, rules)
+		})
+	}
+}
+
+func TestValidateEventsStrict(t *testing.T) {
+	cfg := &configs.Config{StartDelta: configs.Duration(30 * time.Second), Laps: 1}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[10:00:01.000]", CompetitorID: 2, ID: 4},
+		{RawTime: "[10:00:02.000]", CompetitorID: 3, ID: 4},
+	}
+
+	diagnostics, err := utils.ValidateEvents(cfg, events, true)
+
+	var validationErr *utils.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, utils.RuleUnregistered, validationErr.Diagnostic.Rule)
+	assert.Equal(t, 2, validationErr.Diagnostic.Index)
+	assert.Equal(t, 2, validationErr.Diagnostic.CompetitorID)
+	assert.Len(t, diagnostics, 2)
+	assert.Equal(t, utils.SeverityWarning, diagnostics[0].Severity)
+}
+
+func TestValidatorAcceptLate(t *testing.T) {
+	cfg := &configs.Config{StartDelta: configs.Duration(30 * time.Second), Laps: 1}
+
+	validator := utils.NewValidator(cfg, true)
+	validator.AcceptLate()
+	require.NoError(t, validator.Check(utils.Event{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 1}))
+	require.NoError(t, validator.Check(utils.Event{RawTime: "[10:00:00.900]", CompetitorID: 2, ID: 1}))
+	require.NoError(t, validator.Check(utils.Event{RawTime: "[10:00:00.950]", CompetitorID: 3, ID: 1}))
+
+	diagnostics := validator.Diagnostics()
+	require.Len(t, diagnostics, 2, "the latest time is kept")
+	for _, diagnostic := range diagnostics {
+		assert.Equal(t, utils.RuleOutOfOrder, diagnostic.Rule)
+		assert.Equal(t, utils.SeverityWarning, diagnostic.Severity)
+	}
+}
+
+func TestValidateEventsJuryDisqualification(t *testing.T) {
+	cfg := &configs.Config{StartDelta: configs.Duration(30 * time.Second), Laps: 1}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[10:00:00.000]", CompetitorID: 2, ID: 1},
+		{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: utils.EventJuryDisqualification},
+		{RawTime: "[10:00:02.000]", CompetitorID: 2, ID: utils.EventJuryDisqualification, ExtraParams: "Doping"},
+		{RawTime: "[10:00:03.000]", CompetitorID: 2, ID: 2, ExtraParams: "10:01:00.000"},
+	}
+
+	diagnostics, err := utils.ValidateEvents(cfg, events, false)
+	require.NoError(t, err)
+
+	rules := make([]utils.Rule, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		rules[i] = diagnostic.Rule
+	}
+	assert.Equal(t, []utils.Rule{utils.RuleInvalidParams, utils.RuleAfterWithdrawal}, rules)
+	assert.Equal(t, utils.SeverityError, diagnostics[0].Severity)
+	assert.Equal(t, utils.SeverityWarning, diagnostics[1].Severity)
+}
+
+func TestValidateEventsFiringLines(t *testing.T) {
+	cfg := &configs.Config{StartDelta: configs.Duration(30 * time.Second), Laps: 1, FiringLines: 2}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:30.000]", CompetitorID: 1, ID: 5, ExtraParams: "3"},
+		{RawTime: "[10:02:40.000]", CompetitorID: 1, ID: 7},
+		{RawTime: "[10:03:30.000]", CompetitorID: 1, ID: 10},
+	}
+
+	diagnostics, err := utils.ValidateEvents(cfg, events, false)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, utils.RuleInvalidParams, diagnostics[0].Rule)
+	assert.Equal(t, utils.RuleFiringLines, diagnostics[1].Rule)
+	assert.Equal(t, "1 firing range visits, expected 2", diagnostics[1].Message)
+}
+
+func TestValidateEventsIndividualPenaltyLoops(t *testing.T) {
+	cfg := &configs.Config{Format: configs.FormatIndividual, StartDelta: configs.Duration(30 * time.Second), Laps: 1}
+
+	events := []utils.Event{
+		{RawTime: "[10:00:00.000]", CompetitorID: 1, ID: 1},
+		{RawTime: "[10:00:01.000]", CompetitorID: 1, ID: 2, ExtraParams: "10:00:30.000"},
+		{RawTime: "[10:00:30.000]", CompetitorID: 1, ID: 4},
+		{RawTime: "[10:02:45.000]", CompetitorID: 1, ID: 8},
+	}
+
+	diagnostics, err := utils.ValidateEvents(cfg, events, false)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, utils.RuleNoPenaltyLoops, diagnostics[0].Rule)
+}